@@ -0,0 +1,269 @@
+package wrapper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"sync"
+)
+
+// errConversationNotBound は、クライアントに関連付けられていない Conversation で送信した場合のエラーです。
+var errConversationNotBound = errors.New("conversation is not bound to a client")
+
+// Conversation は、UnifiedClient に関連付けられた会話のセッションです。
+// システムプロンプトと会話履歴を保持し、アシスタントの応答を自動的に履歴に追加します。
+//
+// 送信に失敗した場合、履歴は変更されません。履歴の参照は複数のゴルーチンから安全に行えますが、同じ会話から同時に送信しないでください。
+type Conversation struct {
+	client *UnifiedClient
+
+	mu       sync.Mutex
+	params   GenTextParams // Model、SystemPrompt、ツール、サンプリングパラメータなど、各ターンで使用するパラメータ
+	messages []Message
+	usage    Usage
+}
+
+// conversationState は、Conversation をJSONとして保存する際の形式です。
+type conversationState struct {
+	Params   GenTextParams `json:"params"`
+	Messages []Message     `json:"messages"`
+	Usage    Usage         `json:"usage"`
+}
+
+// NewConversation は、このクライアントを使用する会話を作成します。
+// params の Model、SystemPrompt、ツール、サンプリングパラメータなどは各ターンで使用され、
+// Messages は会話履歴の初期値になります。Prompt は使用しません。
+func (c *UnifiedClient) NewConversation(params GenTextParams) *Conversation {
+	conv := &Conversation{client: c}
+	conv.setParams(params)
+	return conv
+}
+
+// ResumeConversation は、MarshalJSON で保存した会話を読み込み、このクライアントを使用して再開します。
+func (c *UnifiedClient) ResumeConversation(data []byte) (*Conversation, error) {
+	conv := &Conversation{}
+	if err := json.Unmarshal(data, conv); err != nil {
+		return nil, err
+	}
+	conv.client = c
+	return conv, nil
+}
+
+// setParams は、各ターンのパラメータと会話履歴を設定します。
+func (conv *Conversation) setParams(params GenTextParams) {
+	conv.messages = slices.Clone(params.Messages)
+	params.Messages = nil
+	params.Prompt = ""
+	conv.params = params
+}
+
+// Model は、次のターンで使用するモデルを返します。
+func (conv *Conversation) Model() Model {
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	return conv.params.Model
+}
+
+// SetModel は、次のターン以降で使用するモデルを変更します。会話履歴はそのまま引き継がれます。
+func (conv *Conversation) SetModel(model Model) {
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	conv.params.Model = model
+}
+
+// SystemPrompt は、会話のシステムプロンプトを返します。
+func (conv *Conversation) SystemPrompt() string {
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	return conv.params.SystemPrompt
+}
+
+// SetSystemPrompt は、次のターン以降で使用するシステムプロンプトを変更します。
+func (conv *Conversation) SetSystemPrompt(systemPrompt string) {
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	conv.params.SystemPrompt = systemPrompt
+}
+
+// SetHistory は、送信時に会話履歴をモデルのコンテキストウィンドウに合わせて調整する戦略を設定します。
+// 調整は送信するメッセージにのみ適用され、保持している会話履歴は変更されません。
+// 戦略はJSONに保存されないため、ResumeConversation で再開した場合は設定し直してください。
+func (conv *Conversation) SetHistory(strategy HistoryStrategy) {
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	conv.params.History = strategy
+}
+
+// Messages は、会話履歴のコピーを返します。
+func (conv *Conversation) Messages() []Message {
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	return slices.Clone(conv.messages)
+}
+
+// Usage は、この会話で使用したトークン数の合計を返します。
+func (conv *Conversation) Usage() Usage {
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	return conv.usage
+}
+
+// Send は、ユーザーのメッセージを送信し、アシスタントの応答を履歴に追加します。
+func (conv *Conversation) Send(ctx context.Context, text string) (*GenTextResponse, error) {
+	return conv.SendMessages(ctx, Message{Role: RoleUser, Content: text})
+}
+
+// SendMessages は、メッセージを履歴に追加して送信し、アシスタントの応答を履歴に追加します。
+// 画像を含むメッセージや、ツールの実行結果（RoleTool）を送信する場合に使用します。
+func (conv *Conversation) SendMessages(ctx context.Context, messages ...Message) (*GenTextResponse, error) {
+	params, err := conv.request(messages)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := conv.client.Generate(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	conv.commit(params.Messages, res)
+	return res, nil
+}
+
+// Stream は、ユーザーのメッセージを送信し、生成されたテキストを逐次返します。
+// 最後のイベントを受け取った時点で、メッセージとアシスタントの応答が履歴に追加されます。
+// 途中で失敗した場合や反復を中断した場合は、履歴は変更されません。
+func (conv *Conversation) Stream(ctx context.Context, text string) iter.Seq2[StreamEvent, error] {
+	return conv.StreamMessages(ctx, Message{Role: RoleUser, Content: text})
+}
+
+// StreamMessages は、メッセージを履歴に追加して送信し、生成されたテキストを逐次返します。
+func (conv *Conversation) StreamMessages(ctx context.Context, messages ...Message) iter.Seq2[StreamEvent, error] {
+	return func(yield func(StreamEvent, error) bool) {
+		params, err := conv.request(messages)
+		if err != nil {
+			yield(StreamEvent{}, err)
+			return
+		}
+
+		for event, err := range conv.client.GenTextStream(ctx, params) {
+			if err == nil && event.Done && event.Response != nil {
+				conv.commit(params.Messages, event.Response)
+			}
+			if !yield(event, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Regenerate は、最後のアシスタントの応答を履歴から取り除き、同じ履歴で応答を生成し直します。
+// 別のモデルで生成し直す場合は、先に SetModel を呼び出してください。
+func (conv *Conversation) Regenerate(ctx context.Context) (*GenTextResponse, error) {
+	if conv.client == nil {
+		return nil, errConversationNotBound
+	}
+
+	conv.mu.Lock()
+	end := len(conv.messages)
+	for end > 0 && conv.messages[end-1].Role == RoleAssistant {
+		end--
+	}
+	if end == 0 {
+		conv.mu.Unlock()
+		return nil, ErrEmptyMessages
+	}
+	params := conv.params
+	params.Messages = slices.Clone(conv.messages[:end])
+	conv.mu.Unlock()
+
+	res, err := conv.client.Generate(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	conv.commit(params.Messages, res)
+	return res, nil
+}
+
+// Undo は、最後のターン（最後のユーザーのメッセージとそれ以降のメッセージ）を履歴から取り除き、取り除いたメッセージを返します。
+// ツール呼び出しを含むターンは、ツールの実行結果や最終的な応答もまとめて取り除かれます。
+func (conv *Conversation) Undo() []Message {
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	for i := len(conv.messages) - 1; i >= 0; i-- {
+		if conv.messages[i].Role == RoleUser {
+			removed := slices.Clone(conv.messages[i:])
+			conv.messages = conv.messages[:i]
+			return removed
+		}
+	}
+	return nil
+}
+
+// Reset は、会話履歴とトークン使用量を消去します。モデルやシステムプロンプトは変更しません。
+func (conv *Conversation) Reset() {
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	conv.messages = nil
+	conv.usage = Usage{}
+}
+
+// MarshalJSON は、パラメータ、会話履歴、トークン使用量をJSONとして出力します。
+// 出力したJSONは、UnifiedClient.ResumeConversation で読み込めます。
+func (conv *Conversation) MarshalJSON() ([]byte, error) {
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	return json.Marshal(conversationState{
+		Params:   conv.params,
+		Messages: conv.messages,
+		Usage:    conv.usage,
+	})
+}
+
+// UnmarshalJSON は、MarshalJSON で出力したJSONから会話を復元します。
+// 復元した会話で送信するには、UnifiedClient.ResumeConversation を使用してください。
+func (conv *Conversation) UnmarshalJSON(data []byte) error {
+	var state conversationState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to decode conversation: %w", err)
+	}
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	state.Params.Messages = state.Messages
+	conv.setParams(state.Params)
+	conv.usage = state.Usage
+	return nil
+}
+
+// request は、現在の履歴に messages を追加したリクエストのパラメータを作成します。
+func (conv *Conversation) request(messages []Message) (GenTextParams, error) {
+	if conv.client == nil {
+		return GenTextParams{}, errConversationNotBound
+	}
+	if len(messages) == 0 {
+		return GenTextParams{}, ErrEmptyMessages
+	}
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	params := conv.params
+	params.Messages = append(slices.Clone(conv.messages), messages...)
+	return params, nil
+}
+
+// commit は、送信したメッセージを含む履歴にアシスタントの応答を追加し、会話履歴とします。
+func (conv *Conversation) commit(sent []Message, res *GenTextResponse) {
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	conv.messages = append(slices.Clone(sent), replyMessage(res))
+	conv.usage.Add(res.Usage)
+}
+
+// replyMessage は、レスポンスを会話履歴に追加するアシスタントのメッセージに変換します。
+func replyMessage(res *GenTextResponse) Message {
+	return Message{Role: RoleAssistant, Content: res.Text, ToolCalls: res.ToolCalls}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
@@ -176,12 +178,62 @@ func unifiedClientExample() {
 	fmt.Println("カスタムモデル 'my-custom-model' を OpenAI プロバイダに登録しました")
 }
 
+// 会話（Conversation）を使用した例
+func conversationExample() {
+	fmt.Println("=== 会話を使用した例 ===")
+
+	apiKeys := map[wrapper.Provider]string{
+		wrapper.ProviderOpenAI: os.Getenv("OPENAI_API_KEY"),
+		wrapper.ProviderGemini: os.Getenv("GEMINI_API_KEY"),
+	}
+
+	client, err := wrapper.NewUnifiedClient(apiKeys, models.Config{MaxToken: 1000})
+	if err != nil {
+		panic(err)
+	}
+
+	// 応答は自動的に履歴に追加される
+	conv := client.NewConversation(wrapper.GenTextParams{
+		Model:        models.Model4_1Nano,
+		SystemPrompt: "簡潔に答えてください。",
+	})
+	ctx := context.Background()
+
+	if _, err := conv.Send(ctx, "田中太郎さんは東京都在住の42歳のエンジニアで、趣味は登山と写真撮影です。彼は先月、富士山に登りました。"); err != nil {
+		panic(err)
+	}
+
+	// 途中でモデルを切り替えても、履歴はそのまま引き継がれる
+	conv.SetModel(models.ModelGemini25FlashPreview)
+	res, err := conv.Send(ctx, "田中さんは先月どこに登りましたか？")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Response: %s\nTokens used: %d\n\n", res.Text, conv.Usage().TotalTokens)
+
+	// 会話を保存して再開する
+	data, err := json.Marshal(conv)
+	if err != nil {
+		panic(err)
+	}
+	resumed, err := client.ResumeConversation(data)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Resumed conversation with %d messages\n", len(resumed.Messages()))
+}
+
 func main() {
 	// 従来の方法（個別のクライアント）を使用した例
 	traditionalExample()
 
-	fmt.Println("\n-----------------------------------\n")
+	fmt.Print("\n-----------------------------------\n\n")
 
 	// 統合クライアントを使用した例
 	unifiedClientExample()
+
+	fmt.Print("\n-----------------------------------\n\n")
+
+	// 会話を使用した例
+	conversationExample()
 }
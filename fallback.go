@@ -0,0 +1,110 @@
+package wrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/obutora/ai-wrapper/internal/telemetry"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// SetFallbacks は、モデルに対するフォールバックチェーンを登録します。
+// model が障害や一時的なエラーで失敗した場合、fallbacks を順に試行します。
+// GenTextParams.Fallbacks が指定されたリクエストでは、そちらが優先されます。
+func (c *UnifiedClient) SetFallbacks(model Model, fallbacks ...Model) {
+	if len(fallbacks) == 0 {
+		delete(c.fallbacks, model)
+		return
+	}
+	c.fallbacks[model] = fallbacks
+}
+
+// modelChain は、リクエストで試行するモデルを順に返します。
+func (c *UnifiedClient) modelChain(params GenTextParams) []Model {
+	fallbacks := params.Fallbacks
+	if len(fallbacks) == 0 {
+		fallbacks = c.fallbacks[params.Model]
+	}
+	return append([]Model{params.Model}, fallbacks...)
+}
+
+// fallbackParams は、フォールバック先のモデルで使用するパラメータを返します。
+func fallbackParams(params GenTextParams, model Model) GenTextParams {
+	params.Model = model
+	params.Fallbacks = nil
+	return params
+}
+
+// fallbackFailure は、失敗したモデルの記録を作成します。
+func (c *UnifiedClient) fallbackFailure(model Model, err error) FallbackFailure {
+	return FallbackFailure{
+		Model:    model,
+		Provider: c.getProviderForModel(model),
+		Err:      err,
+		Message:  err.Error(),
+	}
+}
+
+// shouldFallback は、エラーが次のモデルへのフォールバックの対象かどうかを判定します。
+// 再試行可能なエラー、プロバイダの障害、レスポンスを受け取る前のネットワークエラー、クライアントが設定されていないプロバイダが対象です。
+// リクエストの内容に問題がある場合やキャンセルされた場合は、フォールバックしません。
+func shouldFallback(err error) bool {
+	if errors.Is(err, ErrRequestCanceled) {
+		return false
+	}
+	if errors.Is(err, ErrUnsupportedProvider) {
+		return true
+	}
+
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return providerErr.Retryable || errors.Is(providerErr, ErrProviderUnavailable)
+	}
+
+	return errors.Is(err, ErrAPIRequest)
+}
+
+// fallbackError は、フォールバックした後に最後のモデルも失敗した場合のエラーを作成します。
+// フォールバックしなかった場合は、最後のエラーをそのまま返します。
+func fallbackError(failures []FallbackFailure, model Model, err error) error {
+	if len(failures) == 0 {
+		return err
+	}
+
+	errs := make([]error, 0, len(failures)+1)
+	for _, failure := range failures {
+		errs = append(errs, fmt.Errorf("%s: %w", failure.Model, failure.Err))
+	}
+	errs = append(errs, fmt.Errorf("%s: %w", model, err))
+	return fmt.Errorf("fallback chain failed after %d models: %w", len(errs), errors.Join(errs...))
+}
+
+// startFallbackSpan は、フォールバックチェーンがある場合に、チェーン全体のスパンを開始します。
+// 各モデルのテキスト生成のスパンは、このスパンの子になります。フォールバックがない場合は、何も記録しないスパンを返します。
+func (c *UnifiedClient) startFallbackSpan(ctx context.Context, chain []Model) (context.Context, trace.Span) {
+	if len(chain) <= 1 {
+		return ctx, noop.Span{}
+	}
+
+	names := make([]string, len(chain))
+	for i, model := range chain {
+		names[i] = string(model)
+	}
+	return telemetry.Tracer(c.tracing).Start(ctx, "fallback "+string(chain[0]),
+		trace.WithAttributes(
+			telemetry.AttrRequestModel.String(string(chain[0])),
+			telemetry.AttrFallbackModels.StringSlice(names),
+		),
+	)
+}
+
+// endFallbackSpan は、応答したモデルと失敗したモデルの数を記録して、フォールバックチェーンのスパンを終了します。
+func endFallbackSpan(span trace.Span, res *GenTextResponse, failures int, err error) {
+	span.SetAttributes(telemetry.AttrFallbackCount.Int(failures))
+	if res != nil {
+		span.SetAttributes(telemetry.AttrResponseModel.String(string(res.Model)))
+	}
+	telemetry.EndSpan(span, err)
+}
@@ -0,0 +1,78 @@
+package wrapper
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/obutora/ai-wrapper/models"
+)
+
+// newFallbackClient は、Anthropicのクライアントのみを設定した UnifiedClient を作成します。
+func newFallbackClient(anthropic *fakeKeyClient) *UnifiedClient {
+	return &UnifiedClient{
+		clients:              map[Provider]LLMWrapper{ProviderAnthropic: anthropic},
+		limiter:              newRateLimiter(),
+		customModelProviders: make(map[Model]Provider),
+		fallbacks:            make(map[Model][]Model),
+	}
+}
+
+func TestFallbackSkipsUnconfiguredProvider(t *testing.T) {
+	anthropic := &fakeKeyClient{name: "anthropic"}
+	client := newFallbackClient(anthropic)
+	client.SetFallbacks(models.ModelGPT4o, models.ModelGemini20Flash, models.ModelClaude37Sonnet)
+
+	t.Run("Generate", func(t *testing.T) {
+		res, err := client.Generate(context.Background(), GenTextParams{Model: models.ModelGPT4o, Prompt: "hi"})
+		if err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+		if res.Text != "anthropic" {
+			t.Errorf("Text = %q, want %q", res.Text, "anthropic")
+		}
+		assertUnsupportedFailures(t, res.FallbackFailures)
+	})
+
+	t.Run("GenTextStream", func(t *testing.T) {
+		var res *GenTextResponse
+		for event, err := range client.GenTextStream(context.Background(), GenTextParams{Model: models.ModelGPT4o, Prompt: "hi"}) {
+			if err != nil {
+				t.Fatalf("GenTextStream() error = %v", err)
+			}
+			if event.Response != nil {
+				res = event.Response
+			}
+		}
+		if res == nil {
+			t.Fatal("GenTextStream() returned no response")
+		}
+		assertUnsupportedFailures(t, res.FallbackFailures)
+	})
+}
+
+func TestFallbackAllUnconfigured(t *testing.T) {
+	client := newFallbackClient(&fakeKeyClient{name: "anthropic"})
+
+	_, err := client.Generate(context.Background(), GenTextParams{
+		Model:     models.ModelGPT4o,
+		Prompt:    "hi",
+		Fallbacks: []Model{models.ModelGemini20Flash},
+	})
+	if !errors.Is(err, ErrUnsupportedProvider) {
+		t.Fatalf("Generate() error = %v, want ErrUnsupportedProvider", err)
+	}
+}
+
+func assertUnsupportedFailures(t *testing.T, failures []FallbackFailure) {
+	t.Helper()
+	want := []Model{models.ModelGPT4o, models.ModelGemini20Flash}
+	if len(failures) != len(want) {
+		t.Fatalf("len(FallbackFailures) = %d, want %d", len(failures), len(want))
+	}
+	for i, failure := range failures {
+		if failure.Model != want[i] || !errors.Is(failure.Err, ErrUnsupportedProvider) {
+			t.Errorf("FallbackFailures[%d] = %s: %v, want %s: ErrUnsupportedProvider", i, failure.Model, failure.Err, want[i])
+		}
+	}
+}
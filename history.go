@@ -0,0 +1,347 @@
+package wrapper
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/obutora/ai-wrapper/models"
+)
+
+const (
+	// historyMessageOverhead は、メッセージごとの区切りに使用されるトークン数の見積もりです。
+	historyMessageOverhead = 4
+	// historyMediaTokens は、画像やドキュメント1つあたりのトークン数の見積もりです。
+	historyMediaTokens = 1000
+	// defaultSummaryTokens は、RollingSummary の要約の既定の最大トークン数です。
+	defaultSummaryTokens = 1024
+)
+
+// summaryInstruction は、RollingSummary が要約の生成に使用するシステムプロンプトです。
+const summaryInstruction = "You summarize conversations between a user and an assistant. " +
+	"Write a concise summary that preserves facts, names, numbers, decisions, open questions and the user's preferences, " +
+	"so that the assistant can continue the conversation from the summary alone. " +
+	"If a previous summary is given, merge it with the new messages into a single summary. " +
+	"Write the summary in the language of the conversation and output only the summary."
+
+// summaryPrefix は、要約を会話履歴に含める際の見出しです。
+const summaryPrefix = "Summary of the earlier conversation:\n"
+
+// HistoryStrategy は、会話履歴をモデルのコンテキストウィンドウに収まるように調整する戦略です。
+type HistoryStrategy = models.HistoryStrategy
+
+// SlidingWindow は、トークン数の上限に収まる範囲で、直近のメッセージを残す戦略です。
+// 古いメッセージはターン（ユーザーのメッセージから次のユーザーのメッセージの前まで）単位で取り除かれ、RoleSystem のメッセージは常に残ります。
+type SlidingWindow struct {
+	// MaxTokens は、入力に使用するトークン数の上限です。0の場合は、モデルのコンテキストウィンドウのみに従います。
+	MaxTokens int
+}
+
+// Fit は、トークン数の見積もりが上限に収まるように、古いターンを取り除きます。
+func (s SlidingWindow) Fit(ctx context.Context, params GenTextParams, budget int) ([]Message, error) {
+	return trimHistory(params, params.Messages, historyLimit(s.MaxTokens, budget)), nil
+}
+
+// KeepLastN は、RoleSystem のメッセージと直近の N 件のメッセージを残す戦略です。
+// 残したメッセージがトークン数の上限に収まらない場合は、SlidingWindow と同様に古いターンをさらに取り除きます。
+type KeepLastN struct {
+	// N は、残すメッセージの件数です（RoleSystem のメッセージを除く）。0の場合は件数で制限しません。
+	// ツール呼び出しの途中で区切らないよう、ターンの先頭から残すため、実際に残る件数は N より少なくなる場合があります。
+	N int
+	// MaxTokens は、入力に使用するトークン数の上限です。0の場合は、モデルのコンテキストウィンドウのみに従います。
+	MaxTokens int
+}
+
+// Fit は、直近の N 件のメッセージを残し、トークン数の上限に収まるように調整します。
+func (k KeepLastN) Fit(ctx context.Context, params GenTextParams, budget int) ([]Message, error) {
+	messages := params.Messages
+	if k.N > 0 {
+		messages = lastMessages(messages, k.N)
+	}
+	return trimHistory(params, messages, historyLimit(k.MaxTokens, budget)), nil
+}
+
+// RollingSummary は、トークン数の上限に収まらない古いターンを、LLMで生成した要約に置き換える戦略です。
+// 要約は RoleSystem のメッセージとして直近のメッセージの前に置かれます。
+// 要約は次のリクエストでも再利用され、収まらなくなった場合は、前回の要約とその後の古いターンを合わせて要約し直します。
+// 要約を保持するため、会話ごとに別の RollingSummary を使用してください。複数のゴルーチンから安全に使用できます。
+type RollingSummary struct {
+	// Client は、要約の生成に使用するクライアントです。UnifiedClient を指定できます。
+	Client LLMWrapper
+	// Model は、要約の生成に使用するモデルです。
+	Model Model
+	// MaxTokens は、入力に使用するトークン数の上限です。0の場合は、モデルのコンテキストウィンドウのみに従います。
+	MaxTokens int
+	// SummaryTokens は、要約の最大トークン数です。0の場合は1024です。
+	SummaryTokens int
+
+	mu      sync.Mutex
+	count   int      // 要約済みのメッセージ数（RoleSystem のメッセージを除く）
+	digest  [32]byte // 要約済みのメッセージのハッシュ
+	summary string
+}
+
+// Fit は、トークン数の上限に収まらない場合に、古いターンを要約に置き換えます。
+func (s *RollingSummary) Fit(ctx context.Context, params GenTextParams, budget int) ([]Message, error) {
+	limit := historyLimit(s.MaxTokens, budget)
+	if limit <= 0 {
+		return params.Messages, nil
+	}
+	system, rest := splitSystem(params.Messages)
+	available := limit - fixedTokens(params) - estimateMessages(system)
+	if estimateMessages(rest) <= available {
+		return params.Messages, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	summaryTokens := cmp.Or(s.SummaryTokens, defaultSummaryTokens)
+	cached := s.summary != "" && s.count <= len(rest) && digestMessages(rest[:s.count]) == s.digest
+	if cached && summaryTokens+estimateMessages(rest[s.count:]) <= available {
+		return s.withSummary(system, rest[s.count:]), nil
+	}
+
+	// 次のターン以降も要約し直さずに済むよう、要約の後に残すメッセージは上限の半分に収めます
+	start := fitStart(rest, (available-summaryTokens)/2)
+	if start == 0 {
+		return params.Messages, nil
+	}
+	// 最後のターンだけでも収まらない場合など、新たに要約するメッセージがなければ前回の要約をそのまま使用します
+	if cached && s.count == start {
+		return s.withSummary(system, rest[start:]), nil
+	}
+	previous, from := "", 0
+	if cached && s.count <= start {
+		previous, from = s.summary, s.count
+	}
+	summary, err := s.summarize(ctx, previous, rest[from:start], summaryTokens)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize history: %w", err)
+	}
+
+	s.count, s.digest, s.summary = start, digestMessages(rest[:start]), summary
+	return s.withSummary(system, rest[start:]), nil
+}
+
+// withSummary は、RoleSystem のメッセージ、要約、直近のメッセージの順に並べた会話履歴を返します。
+func (s *RollingSummary) withSummary(system, recent []Message) []Message {
+	messages := slices.Clone(system)
+	messages = append(messages, Message{Role: RoleSystem, Content: summaryPrefix + s.summary})
+	return append(messages, recent...)
+}
+
+// summarize は、前回の要約と新しいメッセージから要約を生成します。
+func (s *RollingSummary) summarize(ctx context.Context, previous string, messages []Message, summaryTokens int) (string, error) {
+	if s.Client == nil {
+		return "", errors.New("RollingSummary.Client is not set")
+	}
+
+	var b strings.Builder
+	if previous != "" {
+		fmt.Fprintf(&b, "Previous summary:\n%s\n\nNew messages:\n", previous)
+	}
+	for _, msg := range messages {
+		fmt.Fprintf(&b, "%s: %s\n", msg.Role, msg.Text())
+		for _, call := range msg.ToolCalls {
+			fmt.Fprintf(&b, "%s: [tool call] %s(%s)\n", msg.Role, call.Name, call.Arguments)
+		}
+	}
+
+	res, err := s.Client.Generate(ctx, GenTextParams{
+		Model:        s.Model,
+		SystemPrompt: summaryInstruction,
+		Prompt:       b.String(),
+		MaxTokens:    summaryTokens,
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(res.Text), nil
+}
+
+// fitHistory は、送信先のモデルのコンテキストウィンドウに合わせて、params.History で会話履歴を調整します。
+// 調整によってメッセージの位置が変わるため、メッセージに置いたキャッシュのブレークポイントは取り除きます。
+func (c *UnifiedClient) fitHistory(ctx context.Context, params GenTextParams) (GenTextParams, error) {
+	if params.History == nil || len(params.Messages) == 0 {
+		return params, nil
+	}
+
+	budget := 0
+	if info, ok := params.Model.Info(); ok && info.ContextWindow > 0 {
+		output := cmp.Or(params.MaxTokens, c.maxToken)
+		budget = max(info.ContextWindow-output, 1)
+	}
+	messages, err := params.History.Fit(ctx, params, budget)
+	if err != nil {
+		return params, err
+	}
+
+	if len(messages) != len(params.Messages) && len(params.CacheBreakpoints) > 0 {
+		params.CacheBreakpoints = slices.DeleteFunc(slices.Clone(params.CacheBreakpoints), func(b CacheBreakpoint) bool {
+			return b.Target == CacheTargetMessage
+		})
+		params.CacheEnabled = true
+	}
+	params.Messages = messages
+	params.History = nil
+	return params, nil
+}
+
+// historyLimit は、戦略の上限とモデルのコンテキストウィンドウのうち小さい方を返します。どちらも指定されていない場合は0です。
+func historyLimit(maxTokens, budget int) int {
+	switch {
+	case maxTokens <= 0:
+		return max(budget, 0)
+	case budget <= 0:
+		return maxTokens
+	default:
+		return min(maxTokens, budget)
+	}
+}
+
+// trimHistory は、トークン数の見積もりが limit に収まるように、古いターンを取り除いたメッセージを返します。
+// limit が0の場合は調整しません。最後のターンだけでも収まらない場合は、最後のターンを残します。
+func trimHistory(params GenTextParams, messages []Message, limit int) []Message {
+	if limit <= 0 {
+		return messages
+	}
+	system, rest := splitSystem(messages)
+	start := fitStart(rest, limit-fixedTokens(params)-estimateMessages(system))
+	if start == 0 {
+		return messages
+	}
+	return append(system, rest[start:]...)
+}
+
+// fitStart は、messages[start:] の見積もりが available に収まる、最も古いターンの開始位置を返します。
+// すべてのメッセージが収まる場合は0を、最後のターンだけでも収まらない場合は最後のターンの開始位置を返します。
+func fitStart(messages []Message, available int) int {
+	total := 0
+	start := -1
+	for i := len(messages) - 1; i >= 0; i-- {
+		total += estimateMessageTokens(messages[i])
+		if total > available {
+			break
+		}
+		if i == 0 {
+			return 0
+		}
+		if messages[i].Role == RoleUser {
+			start = i
+		}
+	}
+	if start >= 0 {
+		return start
+	}
+
+	for i := len(messages) - 1; i > 0; i-- {
+		if messages[i].Role == RoleUser {
+			return i
+		}
+	}
+	return 0
+}
+
+// lastMessages は、RoleSystem のメッセージと、直近の n 件以内のメッセージをターンの先頭から返します。
+func lastMessages(messages []Message, n int) []Message {
+	system, rest := splitSystem(messages)
+	if len(rest) <= n {
+		return messages
+	}
+
+	start := len(rest) - n
+	for start < len(rest) && rest[start].Role != RoleUser {
+		start++
+	}
+	if start == len(rest) {
+		// 直近の n 件にターンの先頭がない場合は、最後のターンを残します
+		start = len(rest) - n
+		for i := len(rest) - n - 1; i >= 0; i-- {
+			if rest[i].Role == RoleUser {
+				start = i
+				break
+			}
+		}
+	}
+	return append(system, rest[start:]...)
+}
+
+// splitSystem は、RoleSystem のメッセージとそれ以外のメッセージに分けます。
+func splitSystem(messages []Message) (system, rest []Message) {
+	for _, msg := range messages {
+		if msg.Role == RoleSystem {
+			system = append(system, msg)
+		} else {
+			rest = append(rest, msg)
+		}
+	}
+	return system, rest
+}
+
+// digestMessages は、メッセージのハッシュを返します。要約済みの履歴が変更されていないかの確認に使用します。
+func digestMessages(messages []Message) [32]byte {
+	data, _ := json.Marshal(messages)
+	return sha256.Sum256(data)
+}
+
+// fixedTokens は、会話履歴以外の入力（システムプロンプト、ツール定義、レスポンスのスキーマ）のトークン数を見積もります。
+func fixedTokens(params GenTextParams) int {
+	tokens := estimateTextTokens(params.SystemPrompt)
+	for _, tool := range params.Tools {
+		definition, _ := json.Marshal(tool)
+		tokens += estimateTextTokens(string(definition))
+	}
+	if params.ResponseFormat != nil {
+		schema, _ := json.Marshal(params.ResponseFormat.Schema)
+		tokens += estimateTextTokens(string(schema))
+	}
+	return tokens
+}
+
+// estimateMessages は、メッセージのトークン数の合計を見積もります。
+func estimateMessages(messages []Message) int {
+	tokens := 0
+	for _, msg := range messages {
+		tokens += estimateMessageTokens(msg)
+	}
+	return tokens
+}
+
+// estimateMessageTokens は、メッセージのトークン数を見積もります。画像やドキュメントは一定のトークン数として数えます。
+func estimateMessageTokens(msg Message) int {
+	tokens := historyMessageOverhead
+	for _, part := range msg.ContentParts() {
+		if part.Type == PartTypeText {
+			tokens += estimateTextTokens(part.Text)
+		} else {
+			tokens += historyMediaTokens
+		}
+	}
+	for _, call := range msg.ToolCalls {
+		tokens += estimateTextTokens(call.Name) + estimateTextTokens(string(call.Arguments))
+	}
+	return tokens
+}
+
+// estimateTextTokens は、テキストのトークン数を見積もります。
+// 多めに見積もるよう、ASCII文字は3文字を1トークン、それ以外の文字（日本語など）は1文字を1トークンとして数えます。
+func estimateTextTokens(text string) int {
+	ascii := 0
+	others := 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			others++
+		}
+	}
+	return (ascii+2)/3 + others
+}
@@ -0,0 +1,246 @@
+package wrapper
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// historyMessage は、id で始まり、本文のトークン数の見積もりが tokens になるメッセージを作成します。
+// メッセージの見積もりは、区切りの historyMessageOverhead を加えた tokens+4 になります。
+func historyMessage(role Role, id string, tokens int) Message {
+	return Message{Role: role, Content: id + strings.Repeat("x", 3*tokens-len(id))}
+}
+
+// toolCallMessage は、ツールを呼び出すアシスタントのメッセージを作成します。見積もりは本文の tokens+4 にツール呼び出しの3を加えた値です。
+func toolCallMessage(id string, tokens int) Message {
+	msg := historyMessage(RoleAssistant, id, tokens)
+	msg.ToolCalls = []ToolCall{{ID: "call_1", Name: "lookup", Arguments: json.RawMessage(`{}`)}}
+	return msg
+}
+
+// messageIDs は、メッセージの id を返します。要約のメッセージは "summary" です。
+func messageIDs(messages []Message) []string {
+	ids := make([]string, len(messages))
+	for i, msg := range messages {
+		if strings.HasPrefix(msg.Content, summaryPrefix) {
+			ids[i] = "summary"
+			continue
+		}
+		ids[i] = msg.Content[:2]
+	}
+	return ids
+}
+
+// 各メッセージの見積もりは、特に記載がなければ14トークン、a2 はツール呼び出しを含むため17トークンです。
+var (
+	// toolConversation は、2番目のターンにツールの呼び出しと結果を含む会話です。
+	toolConversation = []Message{
+		historyMessage(RoleSystem, "s1", 10),
+		historyMessage(RoleUser, "u1", 10),
+		historyMessage(RoleAssistant, "a1", 10),
+		historyMessage(RoleUser, "u2", 10),
+		toolCallMessage("a2", 10),
+		historyMessage(RoleTool, "t2", 10),
+		historyMessage(RoleAssistant, "b2", 10),
+		historyMessage(RoleUser, "u3", 10),
+		historyMessage(RoleAssistant, "a3", 10),
+	}
+	// longToolConversation は、toolConversation の前に104トークンの長いターンを加えた会話です。
+	longToolConversation = slices.Concat(toolConversation[:1], []Message{
+		historyMessage(RoleUser, "u0", 100),
+		historyMessage(RoleAssistant, "a0", 10),
+	}, toolConversation[1:])
+	// pendingToolConversation は、最後のターンがツールの結果で終わる会話です。
+	pendingToolConversation = []Message{
+		historyMessage(RoleUser, "u1", 10),
+		historyMessage(RoleAssistant, "a1", 10),
+		historyMessage(RoleUser, "u2", 10),
+		toolCallMessage("a2", 10),
+		historyMessage(RoleTool, "t2", 10),
+	}
+)
+
+func TestHistoryStrategies(t *testing.T) {
+	tests := []struct {
+		name         string
+		strategy     HistoryStrategy
+		messages     []Message
+		systemPrompt string
+		budget       int
+		want         []string
+	}{
+		{
+			name:     "fits",
+			strategy: SlidingWindow{MaxTokens: 1000},
+			messages: toolConversation,
+			want:     []string{"s1", "u1", "a1", "u2", "a2", "t2", "b2", "u3", "a3"},
+		},
+		{
+			name:     "no limit",
+			strategy: SlidingWindow{},
+			messages: toolConversation,
+			want:     []string{"s1", "u1", "a1", "u2", "a2", "t2", "b2", "u3", "a3"},
+		},
+		{
+			name:     "drops the oldest turn",
+			strategy: SlidingWindow{MaxTokens: 110},
+			messages: toolConversation,
+			want:     []string{"s1", "u2", "a2", "t2", "b2", "u3", "a3"},
+		},
+		{
+			name:     "context window is smaller than the strategy limit",
+			strategy: SlidingWindow{MaxTokens: 1000},
+			messages: toolConversation,
+			budget:   110,
+			want:     []string{"s1", "u2", "a2", "t2", "b2", "u3", "a3"},
+		},
+		{
+			// 末尾から t2 までは収まりますが、ツールの結果を呼び出しから切り離さないよう、ターン単位で取り除きます
+			name:     "does not split a tool call from its result",
+			strategy: SlidingWindow{MaxTokens: 70},
+			messages: toolConversation,
+			want:     []string{"s1", "u3", "a3"},
+		},
+		{
+			name:     "keeps the newest turn when nothing fits",
+			strategy: SlidingWindow{MaxTokens: 20},
+			messages: toolConversation,
+			want:     []string{"s1", "u3", "a3"},
+		},
+		{
+			name:     "keeps the newest turn with its tool call and result",
+			strategy: SlidingWindow{MaxTokens: 20},
+			messages: pendingToolConversation,
+			want:     []string{"u2", "a2", "t2"},
+		},
+		{
+			name:         "system prompt over budget",
+			strategy:     SlidingWindow{MaxTokens: 100},
+			messages:     toolConversation,
+			systemPrompt: strings.Repeat("x", 3*300),
+			want:         []string{"s1", "u3", "a3"},
+		},
+		{
+			name:     "keep last N",
+			strategy: KeepLastN{N: 2},
+			messages: toolConversation,
+			want:     []string{"s1", "u3", "a3"},
+		},
+		{
+			name:     "keep last N starts at a turn",
+			strategy: KeepLastN{N: 5},
+			messages: toolConversation,
+			want:     []string{"s1", "u3", "a3"},
+		},
+		{
+			name:     "keep last N includes a whole tool turn",
+			strategy: KeepLastN{N: 6},
+			messages: toolConversation,
+			want:     []string{"s1", "u2", "a2", "t2", "b2", "u3", "a3"},
+		},
+		{
+			name:     "keep last N does not start at a tool result",
+			strategy: KeepLastN{N: 1},
+			messages: pendingToolConversation,
+			want:     []string{"u2", "a2", "t2"},
+		},
+		{
+			name:     "keep last N with a token limit",
+			strategy: KeepLastN{N: 6, MaxTokens: 70},
+			messages: toolConversation,
+			want:     []string{"s1", "u3", "a3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := GenTextParams{SystemPrompt: tt.systemPrompt, Messages: tt.messages}
+			messages, err := tt.strategy.Fit(context.Background(), params, tt.budget)
+			if err != nil {
+				t.Fatalf("Fit() error = %v", err)
+			}
+			if got := messageIDs(messages); !slices.Equal(got, tt.want) {
+				t.Errorf("Fit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRollingSummary(t *testing.T) {
+	tests := []struct {
+		name         string
+		summary      *RollingSummary
+		messages     []Message
+		systemPrompt string
+		want         []string
+		wantCalls    int
+	}{
+		{
+			name:     "fits",
+			summary:  &RollingSummary{MaxTokens: 1000},
+			messages: toolConversation,
+			want:     []string{"s1", "u1", "a1", "u2", "a2", "t2", "b2", "u3", "a3"},
+		},
+		{
+			// 要約の後に残すメッセージは (200-14-10)/2 = 88 トークンに収めます
+			name:      "summarizes old turns",
+			summary:   &RollingSummary{MaxTokens: 200, SummaryTokens: 10},
+			messages:  longToolConversation,
+			want:      []string{"s1", "summary", "u2", "a2", "t2", "b2", "u3", "a3"},
+			wantCalls: 1,
+		},
+		{
+			// 末尾から t2 までは (144-14-10)/2 = 60 トークンに収まりますが、ターン単位で要約します
+			name:      "does not split a tool call from its result",
+			summary:   &RollingSummary{MaxTokens: 144, SummaryTokens: 10},
+			messages:  longToolConversation,
+			want:      []string{"s1", "summary", "u3", "a3"},
+			wantCalls: 1,
+		},
+		{
+			name:         "system prompt over budget",
+			summary:      &RollingSummary{MaxTokens: 100, SummaryTokens: 10},
+			messages:     toolConversation,
+			systemPrompt: strings.Repeat("x", 3*300),
+			want:         []string{"s1", "summary", "u3", "a3"},
+			wantCalls:    1,
+		},
+		{
+			name:      "keeps the newest turn",
+			summary:   &RollingSummary{MaxTokens: 40, SummaryTokens: 10},
+			messages:  pendingToolConversation,
+			want:      []string{"summary", "u2", "a2", "t2"},
+			wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeKeyClient{name: "the user asked about the weather"}
+			tt.summary.Client = client
+			params := GenTextParams{SystemPrompt: tt.systemPrompt, Messages: tt.messages}
+
+			messages, err := tt.summary.Fit(context.Background(), params, 0)
+			if err != nil {
+				t.Fatalf("Fit() error = %v", err)
+			}
+			if got := messageIDs(messages); !slices.Equal(got, tt.want) {
+				t.Errorf("Fit() = %v, want %v", got, tt.want)
+			}
+			if client.calls != tt.wantCalls {
+				t.Errorf("summaries generated = %d, want %d", client.calls, tt.wantCalls)
+			}
+
+			// 同じ履歴では、前回の要約を再利用します
+			if _, err := tt.summary.Fit(context.Background(), params, 0); err != nil {
+				t.Fatalf("second Fit() error = %v", err)
+			}
+			if client.calls != tt.wantCalls {
+				t.Errorf("summaries generated after the second Fit = %d, want %d", client.calls, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,317 @@
+// Package jsonschema は、Goの型からJSON Schemaを生成し、JSONデータを検証する機能を提供します。
+// 構造化出力で利用するため、各プロバイダが共通してサポートするキーワードのみを扱います。
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Generate は、Goの型からJSON Schemaを生成します。
+//
+// 構造体のフィールドは json タグの名前で出力され、すべて required になります。
+// ポインタ型のフィールドは null を許容します（OpenAIのstrictモードと互換性を保つため）。
+// description タグを指定すると、フィールドの説明として出力されます。
+//
+// 各プロバイダの構造化出力はルートがオブジェクトで、プロパティが固定されたスキーマを要求するため、
+// ルートが構造体（またはそのポインタ）でない型や、マップ、インターフェース、再帰的な型を含む型はエラーになります。
+func Generate(t reflect.Type) (map[string]any, error) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType {
+		return nil, fmt.Errorf("root type %s must be a struct", t)
+	}
+	return generate(t, "$", map[reflect.Type]bool{})
+}
+
+// timeType は、time.Time の型です。RFC 3339 形式の文字列として出力されます。
+var timeType = reflect.TypeOf(time.Time{})
+
+func generate(t reflect.Type, path string, visiting map[reflect.Type]bool) (map[string]any, error) {
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema, err := generate(t.Elem(), path, visiting)
+		if err != nil {
+			return nil, err
+		}
+		if typ, ok := schema["type"].(string); ok {
+			schema["type"] = []any{typ, "null"}
+		}
+		return schema, nil
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}, nil
+	case reflect.String:
+		return map[string]any{"type": "string"}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// []byte は、base64文字列としてエンコードされます
+			return map[string]any{"type": "string"}, nil
+		}
+		items, err := generate(t.Elem(), path+"[]", visiting)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "array", "items": items}, nil
+	case reflect.Map:
+		// 任意のキーを持つオブジェクトは、OpenAIのstrictモードで使用できません
+		return nil, fmt.Errorf("%s: map type %s is not supported; use a struct or a slice of key-value structs", path, t)
+	case reflect.Interface:
+		return nil, fmt.Errorf("%s: interface type %s is not supported", path, t)
+	case reflect.Struct:
+		if visiting[t] {
+			return nil, fmt.Errorf("%s: recursive type %s is not supported", path, t)
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+		return generateStruct(t, path, visiting)
+	default:
+		return nil, fmt.Errorf("%s: type %s is not supported", path, t)
+	}
+}
+
+func generateStruct(t reflect.Type, path string, visiting map[reflect.Type]bool) (map[string]any, error) {
+	properties := map[string]any{}
+	required := []string{}
+
+	var addFields func(t reflect.Type) error
+	addFields = func(t reflect.Type) error {
+		for i := range t.NumField() {
+			field := t.Field(i)
+			tag := field.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, _, _ := strings.Cut(tag, ",")
+
+			// 埋め込み構造体のフィールドは、親の構造体に展開します
+			if field.Anonymous && name == "" {
+				ft := field.Type
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					if err := addFields(ft); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+			if !field.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+
+			schema, err := generate(field.Type, path+"."+name, visiting)
+			if err != nil {
+				return err
+			}
+			if description := field.Tag.Get("description"); description != "" {
+				schema["description"] = description
+			}
+			properties[name] = schema
+			required = append(required, name)
+		}
+		return nil
+	}
+	if err := addFields(t); err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}, nil
+}
+
+// Validate は、JSONデータがスキーマに適合しているかを検証します。
+// 適合しない場合は、違反箇所のパスを含むエラーを返します。
+func Validate(schema map[string]any, data []byte) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	return validate(schema, value, "$")
+}
+
+func validate(schema map[string]any, value any, path string) error {
+	if len(schema) == 0 {
+		return nil
+	}
+
+	types := schemaTypes(schema["type"])
+	if len(types) > 0 && !matchesAnyType(types, value) {
+		return fmt.Errorf("%s: expected %s, got %s", path, strings.Join(types, " or "), jsonType(value))
+	}
+
+	if enum := enumValues(schema["enum"]); enum != nil && !containsValue(enum, value) {
+		return fmt.Errorf("%s: value %v is not one of %v", path, value, enum)
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		for _, name := range requiredNames(schema["required"]) {
+			if _, ok := v[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			propertyPath := path + "." + key
+			if property, ok := properties[key].(map[string]any); ok {
+				if err := validate(property, v[key], propertyPath); err != nil {
+					return err
+				}
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					return fmt.Errorf("%s: unexpected property", propertyPath)
+				}
+			case map[string]any:
+				if err := validate(additional, v[key], propertyPath); err != nil {
+					return err
+				}
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				if err := validate(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// schemaTypes は、type キーワードの値を文字列のスライスに変換します。
+func schemaTypes(v any) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []any:
+		types := make([]string, 0, len(t))
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	case []string:
+		return t
+	default:
+		return nil
+	}
+}
+
+// requiredNames は、required キーワードの値を文字列のスライスに変換します。
+func requiredNames(v any) []string {
+	return schemaTypes(v)
+}
+
+func matchesAnyType(types []string, value any) bool {
+	for _, t := range types {
+		if matchesType(t, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesType(t string, value any) bool {
+	switch t {
+	case "null":
+		return value == nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == float64(int64(n))
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	default:
+		return true
+	}
+}
+
+func jsonType(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// enumValues は、enum キーワードの値を []any に変換します。
+func enumValues(v any) []any {
+	switch values := v.(type) {
+	case []any:
+		return values
+	case []string:
+		result := make([]any, len(values))
+		for i, value := range values {
+			result[i] = value
+		}
+		return result
+	default:
+		return nil
+	}
+}
+
+func containsValue(values []any, value any) bool {
+	for _, v := range values {
+		if reflect.DeepEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+package jsonschema
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type address struct {
+	City string `json:"city" description:"City name"`
+}
+
+type base struct {
+	ID int `json:"id"`
+}
+
+type person struct {
+	base
+	Name      string    `json:"name"`
+	Email     *string   `json:"email"`
+	Tags      []string  `json:"tags"`
+	Addresses []address `json:"addresses"`
+	Born      time.Time `json:"born"`
+	Avatar    []byte    `json:"avatar"`
+	Ignored   string    `json:"-"`
+	internal  string
+}
+
+type withMap struct {
+	Scores map[string]int `json:"scores"`
+}
+
+type withInterface struct {
+	Value any `json:"value"`
+}
+
+type node struct {
+	Name     string `json:"name"`
+	Children []node `json:"children"`
+}
+
+type linked struct {
+	Value int     `json:"value"`
+	Next  *linked `json:"next"`
+}
+
+func TestGenerateStruct(t *testing.T) {
+	schema, err := Generate(reflect.TypeFor[person]())
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	want := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"id":    map[string]any{"type": "integer"},
+			"name":  map[string]any{"type": "string"},
+			"email": map[string]any{"type": []any{"string", "null"}},
+			"tags":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+			"addresses": map[string]any{"type": "array", "items": map[string]any{
+				"type":                 "object",
+				"properties":           map[string]any{"city": map[string]any{"type": "string", "description": "City name"}},
+				"required":             []string{"city"},
+				"additionalProperties": false,
+			}},
+			"born":   map[string]any{"type": "string", "format": "date-time"},
+			"avatar": map[string]any{"type": "string"},
+		},
+		"required":             []string{"id", "name", "email", "tags", "addresses", "born", "avatar"},
+		"additionalProperties": false,
+	}
+	if !reflect.DeepEqual(schema, want) {
+		t.Errorf("Generate() =\n%v\nwant\n%v", schema, want)
+	}
+}
+
+func TestGenerateRoot(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     reflect.Type
+		wantErr string
+	}{
+		{name: "struct", typ: reflect.TypeFor[address]()},
+		{name: "pointer to struct", typ: reflect.TypeFor[*address]()},
+		{name: "slice", typ: reflect.TypeFor[[]address](), wantErr: "must be a struct"},
+		{name: "map", typ: reflect.TypeFor[map[string]string](), wantErr: "must be a struct"},
+		{name: "string", typ: reflect.TypeFor[string](), wantErr: "must be a struct"},
+		{name: "time", typ: reflect.TypeFor[time.Time](), wantErr: "must be a struct"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := Generate(tt.typ)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Generate() error = %v", err)
+				}
+				if schema["type"] != "object" {
+					t.Errorf("root type = %v, want object", schema["type"])
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Generate() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenerateUnsupportedTypes(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     reflect.Type
+		wantErr string
+	}{
+		{name: "map field", typ: reflect.TypeFor[withMap](), wantErr: "$.scores: map type"},
+		{name: "interface field", typ: reflect.TypeFor[withInterface](), wantErr: "$.value: interface type"},
+		{name: "recursive slice", typ: reflect.TypeFor[node](), wantErr: "$.children[]: recursive type"},
+		{name: "recursive pointer", typ: reflect.TypeFor[linked](), wantErr: "$.next: recursive type"},
+		{name: "func field", typ: reflect.TypeFor[struct {
+			F func() `json:"f"`
+		}](), wantErr: "$.f: type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Generate(tt.typ); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Generate() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenerateSameTypeTwice(t *testing.T) {
+	// 同じ型を兄弟のフィールドで使用するのは、再帰ではありません
+	type pair struct {
+		From address `json:"from"`
+		To   address `json:"to"`
+	}
+	if _, err := Generate(reflect.TypeFor[pair]()); err != nil {
+		t.Errorf("Generate() error = %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	schema, err := Generate(reflect.TypeFor[address]())
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{name: "valid", data: `{"city": "Tokyo"}`},
+		{name: "missing property", data: `{}`, wantErr: `missing required property "city"`},
+		{name: "wrong type", data: `{"city": 1}`, wantErr: "$.city: expected string, got number"},
+		{name: "unexpected property", data: `{"city": "Tokyo", "zip": "100"}`, wantErr: "$.zip: unexpected property"},
+		{name: "invalid JSON", data: `{`, wantErr: "invalid JSON"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(schema, []byte(tt.data))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -2,7 +2,13 @@ package providers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"iter"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
@@ -17,56 +23,220 @@ type AnthropicClient struct {
 
 // NewAnthropicClient は、Anthropicクライアントの新しいインスタンスを作成します。
 func NewAnthropicClient(apiKey string, config models.Config) *AnthropicClient {
-	client := anthropic.NewClient(
-		option.WithAPIKey(apiKey),
-	)
+	// SDKによる再試行は各試行のフックやレート制限を通らないため常に無効にし、
+	// 再試行ポリシーが未指定の場合はSDKと同じ回数を再試行する既定のポリシーを使用します
+	opts := []option.RequestOption{option.WithAPIKey(apiKey), option.WithMaxRetries(0)}
+	if config.Retry.MaxAttempts == 0 {
+		config.Retry = models.DefaultRetryPolicy()
+	}
+	client := anthropic.NewClient(opts...)
 	return &AnthropicClient{client: client, config: config}
 }
 
 // GenText は、Anthropic APIを使用してテキストを生成します。
 func (c *AnthropicClient) GenText(params models.GenTextParams) (string, error, int) {
-	if params.Model == "" {
-		return "", models.ErrInvalidModel, 0
+	return c.GenTextContext(context.Background(), params)
+}
+
+// GenTextContext は、コンテキストを指定してAnthropic APIでテキストを生成します。
+// コンテキストのキャンセルやデッドラインはSDKの呼び出しに伝播されます。
+func (c *AnthropicClient) GenTextContext(ctx context.Context, params models.GenTextParams) (string, error, int) {
+	return legacyResult(c.Generate(ctx, params))
+}
+
+// Generate は、Anthropic APIを使用してテキストを生成し、詳細なレスポンスを返します。
+func (c *AnthropicClient) Generate(ctx context.Context, params models.GenTextParams) (*models.GenTextResponse, error) {
+	return instrumentGenerate(ctx, c.config, models.ProviderAnthropic, params, c.generate)
+}
+
+// generate は、Anthropic APIを呼び出してテキストを生成します。
+func (c *AnthropicClient) generate(ctx context.Context, params models.GenTextParams) (*models.GenTextResponse, error) {
+	if err := validateParams(params); err != nil {
+		return nil, err
+	}
+	if err := checkContextWindow(ctx, c, params, c.config); err != nil {
+		return nil, err
+	}
+
+	messageParams, err := c.buildParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	// APIリクエストを実行
+	var httpRes *http.Response
+	var response *anthropic.Message
+	start := time.Now()
+	attempts, err := withRetry(ctx, c.config.Retry, func() error {
+		var err error
+		response, err = c.client.Messages.New(ctx, messageParams, option.WithResponseInto(&httpRes))
+		return err
+	})
+	if err != nil {
+		return nil, wrapAPIError(ctx, err)
+	}
+	latency := time.Since(start)
+
+	// レスポンスからテキストを取得
+	if len(response.Content) == 0 {
+		if anthropicFinishReason(response.StopReason) == models.FinishReasonSafety {
+			return nil, contentFilteredError("response blocked: " + string(response.StopReason))
+		}
+		return nil, wrapAPIError(ctx, errors.New("no content returned"))
+	}
+
+	res := anthropicResponse(response)
+	applyAnthropicResponseFormat(res, params.ResponseFormat)
+	res.Latency = latency
+	res.RequestID = requestID(httpRes, "request-id")
+	res.Attempts = attempts
+	res.Cost = cost(c.config, res.Usage, res.Model, params.Model)
+
+	return res, nil
+}
+
+// GenTextStream は、Anthropic APIのストリーミングを使用してテキストを逐次生成します。
+// 最終イベントには、終了理由とトークン使用量が含まれます。
+func (c *AnthropicClient) GenTextStream(ctx context.Context, params models.GenTextParams) iter.Seq2[models.StreamEvent, error] {
+	return instrumentStream(ctx, c.config, models.ProviderAnthropic, params, c.genTextStream)
+}
+
+// genTextStream は、Anthropic APIのストリーミングを呼び出してテキストを逐次生成します。
+func (c *AnthropicClient) genTextStream(ctx context.Context, params models.GenTextParams) iter.Seq2[models.StreamEvent, error] {
+	if err := validateStreamParams(params); err != nil {
+		return errStream(err)
+	}
+	if err := checkContextWindow(ctx, c, params, c.config); err != nil {
+		return errStream(err)
 	}
 
-	if len(params.Messages) == 0 && params.Prompt == "" {
-		return "", models.ErrEmptyMessages, 0
+	messageParams, err := c.buildParams(params)
+	if err != nil {
+		return errStream(err)
+	}
+
+	return retryStream(ctx, c.config.Retry, func(yield func(models.StreamEvent, error) bool) {
+		var httpRes *http.Response
+		start := time.Now()
+		stream := c.client.Messages.NewStreaming(ctx, messageParams, option.WithResponseInto(&httpRes))
+		defer stream.Close()
+
+		// 受信したイベントを蓄積して、最終的な終了理由と使用量を取得します
+		message := anthropic.Message{}
+		for stream.Next() {
+			event := stream.Current()
+			if err := message.Accumulate(event); err != nil {
+				yield(models.StreamEvent{}, fmt.Errorf("%w: %v", models.ErrAPIRequest, err))
+				return
+			}
+
+			if event.Type != "content_block_delta" {
+				continue
+			}
+			delta := event.Delta.Text
+			if params.ResponseFormat != nil {
+				// 構造化出力では、ツール呼び出しの引数（JSON）の差分をテキストとして返します
+				delta = event.Delta.PartialJSON
+			}
+			if delta != "" {
+				if !yield(models.StreamEvent{Delta: delta}, nil) {
+					return
+				}
+			}
+		}
+		if err := stream.Err(); err != nil {
+			yield(models.StreamEvent{}, wrapAPIError(ctx, err))
+			return
+		}
+
+		res := anthropicResponse(&message)
+		applyAnthropicResponseFormat(res, params.ResponseFormat)
+		res.Latency = time.Since(start)
+		res.Cost = cost(c.config, res.Usage, res.Model, params.Model)
+		res.RequestID = requestID(httpRes, "request-id")
+
+		yield(models.StreamEvent{
+			Done:         true,
+			FinishReason: res.FinishReason,
+			Usage:        res.Usage,
+			Response:     res,
+		}, nil)
+	})
+}
+
+// buildParams は、共通パラメータをAnthropic APIのリクエストパラメータに変換します。
+func (c *AnthropicClient) buildParams(params models.GenTextParams) (anthropic.MessageNewParams, error) {
+	// seed、ペナルティ、コンテキストキャッシュの参照はAnthropicでサポートされていません
+	if err := checkUnsupportedParams(params, paramSeed, paramPresencePenalty, paramFrequencyPenalty, paramCachedContent); err != nil {
+		return anthropic.MessageNewParams{}, err
 	}
 
-	ctx := context.Background()
 	messages := []anthropic.MessageParam{}
+	// 各メッセージが変換後にどのブロックになったかを記録し、キャッシュのブレークポイントの設定に使用します
+	positions := map[int]anthropicBlockPosition{}
 
 	// メッセージがある場合は、それらを変換して使用します
 	if len(params.Messages) > 0 {
-		for _, msg := range params.Messages {
+		for i, msg := range params.Messages {
 			var role anthropic.MessageParamRole
 			switch msg.Role {
-			case models.RoleUser:
+			case models.RoleUser, models.RoleTool:
 				role = anthropic.MessageParamRoleUser
 			case models.RoleAssistant:
 				role = anthropic.MessageParamRoleAssistant
 			case models.RoleSystem:
-				// Anthropicでは、システムメッセージは特別な処理が必要
-				// システムメッセージはシステムプロンプトとして扱います
+				// システムメッセージは、トップレベルの System フィールドで送信します
 				continue
 			default:
 				role = anthropic.MessageParamRoleUser
 			}
 
-			content := []anthropic.ContentBlockParamUnion{
-				{
-					OfRequestTextBlock: &anthropic.TextBlockParam{
-						Text: msg.Content,
-						// cacheを有効化
-						CacheControl: anthropic.CacheControlEphemeralParam{},
+			// ツールの実行結果は、tool_result ブロックを含むユーザーメッセージとして送信します
+			// 並列で呼び出されたツールの結果は、1つのメッセージにまとめます
+			if msg.Role == models.RoleTool {
+				block := anthropic.NewToolResultBlock(msg.ToolCallID, msg.Content, false)
+				if i > 0 && params.Messages[i-1].Role == models.RoleTool && len(messages) > 0 {
+					last := &messages[len(messages)-1]
+					last.Content = append(last.Content, block)
+					positions[i] = lastAnthropicBlock(messages)
+					continue
+				}
+				messages = append(messages, anthropic.MessageParam{
+					Role:    role,
+					Content: []anthropic.ContentBlockParamUnion{block},
+				})
+				positions[i] = lastAnthropicBlock(messages)
+				continue
+			}
+
+			content := []anthropic.ContentBlockParamUnion{}
+			if msg.Content != "" || (len(msg.ToolCalls) == 0 && len(msg.Parts) == 0) {
+				content = append(content, anthropic.ContentBlockParamUnion{
+					OfRequestTextBlock: &anthropic.TextBlockParam{Text: msg.Content},
+				})
+			}
+			for _, part := range msg.Parts {
+				content = append(content, anthropicContentBlock(part))
+			}
+			for _, call := range msg.ToolCalls {
+				input := call.Arguments
+				if len(input) == 0 {
+					input = json.RawMessage("{}")
+				}
+				content = append(content, anthropic.ContentBlockParamUnion{
+					OfRequestToolUseBlock: &anthropic.ToolUseBlockParam{
+						ID:    call.ID,
+						Name:  call.Name,
+						Input: input,
 					},
-				},
+				})
 			}
 
 			messages = append(messages, anthropic.MessageParam{
 				Role:    role,
 				Content: content,
 			})
+			positions[i] = lastAnthropicBlock(messages)
 		}
 	} else if params.Prompt != "" {
 		// プロンプトがある場合は、ユーザーメッセージとして追加します
@@ -82,6 +252,7 @@ func (c *AnthropicClient) GenText(params models.GenTextParams) (string, error, i
 			Role:    anthropic.MessageParamRoleUser,
 			Content: content,
 		})
+		positions[0] = lastAnthropicBlock(messages)
 	}
 
 	// モデル名を取得
@@ -91,25 +262,278 @@ func (c *AnthropicClient) GenText(params models.GenTextParams) (string, error, i
 	messageParams := anthropic.MessageNewParams{
 		Model:     model,
 		Messages:  messages,
-		MaxTokens: int64(c.config.MaxToken),
+		MaxTokens: maxTokens(params, c.config),
 	}
 
-	// APIリクエストを実行
-	response, err := c.client.Messages.New(ctx, messageParams)
-	if err != nil {
-		return "", fmt.Errorf("%w: %v", models.ErrAPIRequest, err), 0
+	// システム指示を設定
+	for _, text := range systemTexts(params) {
+		messageParams.System = append(messageParams.System, anthropic.TextBlockParam{Text: text})
 	}
 
-	// レスポンスからテキストを取得
-	if len(response.Content) == 0 {
-		return "", fmt.Errorf("no content returned"), 0
+	// サンプリングパラメータを設定
+	if params.Temperature != nil {
+		messageParams.Temperature = anthropic.Float(*params.Temperature)
+	}
+	if params.TopP != nil {
+		messageParams.TopP = anthropic.Float(*params.TopP)
+	}
+	if params.TopK != nil {
+		messageParams.TopK = anthropic.Int(int64(*params.TopK))
 	}
+	messageParams.StopSequences = params.StopSequences
 
-	// レスポンスからテキストを取得
-	text := response.Content[0].Text
+	// ツールを設定
+	for _, tool := range params.Tools {
+		inputSchema, err := anthropicInputSchema(tool.Parameters)
+		if err != nil {
+			return anthropic.MessageNewParams{}, fmt.Errorf("tool %s: %w", tool.Name, err)
+		}
+		toolParam := &anthropic.ToolParam{
+			Name:        tool.Name,
+			InputSchema: inputSchema,
+		}
+		if tool.Description != "" {
+			toolParam.Description = anthropic.String(tool.Description)
+		}
+		messageParams.Tools = append(messageParams.Tools, anthropic.ToolUnionParam{OfTool: toolParam})
+	}
+	switch params.ToolChoice {
+	case "":
+	case models.ToolChoiceAuto:
+		messageParams.ToolChoice = anthropic.ToolChoiceUnionParam{OfToolChoiceAuto: &anthropic.ToolChoiceAutoParam{}}
+	case models.ToolChoiceNone:
+		messageParams.ToolChoice = anthropic.ToolChoiceUnionParam{OfToolChoiceNone: &anthropic.ToolChoiceNoneParam{}}
+	case models.ToolChoiceRequired:
+		messageParams.ToolChoice = anthropic.ToolChoiceUnionParam{OfToolChoiceAny: &anthropic.ToolChoiceAnyParam{}}
+	default:
+		messageParams.ToolChoice = anthropic.ToolChoiceUnionParam{
+			OfToolChoiceTool: &anthropic.ToolChoiceToolParam{Name: string(params.ToolChoice)},
+		}
+	}
 
-	// トークン数を取得
-	tokens := int(response.Usage.OutputTokens + response.Usage.InputTokens)
+	// 構造化出力は、スキーマを入力とするツールの呼び出しを強制することで実現します
+	if format := params.ResponseFormat; format != nil {
+		name := responseFormatName(format)
+		inputSchema, err := anthropicInputSchema(format.Schema)
+		if err != nil {
+			return anthropic.MessageNewParams{}, fmt.Errorf("response format: %w", err)
+		}
+		toolParam := &anthropic.ToolParam{
+			Name:        name,
+			InputSchema: inputSchema,
+		}
+		if format.Description != "" {
+			toolParam.Description = anthropic.String(format.Description)
+		}
+		messageParams.Tools = append(messageParams.Tools, anthropic.ToolUnionParam{OfTool: toolParam})
+		messageParams.ToolChoice = anthropic.ToolChoiceUnionParam{
+			OfToolChoiceTool: &anthropic.ToolChoiceToolParam{Name: name},
+		}
+	}
+
+	// プロンプトキャッシュのブレークポイントを設定
+	if err := applyAnthropicCacheControl(&messageParams, params, positions); err != nil {
+		return anthropic.MessageNewParams{}, err
+	}
+
+	return messageParams, nil
+}
+
+// anthropicMaxCacheBreakpoints は、1回のリクエストで指定できるキャッシュのブレークポイントの最大数です。
+const anthropicMaxCacheBreakpoints = 4
+
+// anthropicBlockPosition は、変換後のメッセージ内のコンテンツブロックの位置を表します。
+type anthropicBlockPosition struct {
+	message int
+	block   int
+}
+
+// lastAnthropicBlock は、最後のメッセージの最後のコンテンツブロックの位置を返します。
+func lastAnthropicBlock(messages []anthropic.MessageParam) anthropicBlockPosition {
+	last := len(messages) - 1
+	return anthropicBlockPosition{message: last, block: len(messages[last].Content) - 1}
+}
+
+// applyAnthropicCacheControl は、キャッシュのブレークポイントに cache_control を設定します。
+// ブレークポイントが未指定で CacheEnabled が有効な場合は、既定のブレークポイントを使用します。
+func applyAnthropicCacheControl(messageParams *anthropic.MessageNewParams, params models.GenTextParams, positions map[int]anthropicBlockPosition) error {
+	breakpoints := params.CacheBreakpoints
+	if len(breakpoints) == 0 {
+		if !params.CacheEnabled {
+			return nil
+		}
+		breakpoints = defaultCacheBreakpoints(params)
+	}
 
-	return text, nil, tokens
+	count := 0
+	for _, breakpoint := range breakpoints {
+		target := breakpoint.Target
+		// システムメッセージへのブレークポイントは、システムプロンプトに置きます
+		if target == models.CacheTargetMessage && breakpoint.MessageIndex < len(params.Messages) &&
+			params.Messages[breakpoint.MessageIndex].Role == models.RoleSystem {
+			target = models.CacheTargetSystem
+		}
+
+		var cacheControl *anthropic.CacheControlEphemeralParam
+		switch target {
+		case models.CacheTargetSystem:
+			if len(messageParams.System) > 0 {
+				cacheControl = &messageParams.System[len(messageParams.System)-1].CacheControl
+			}
+		case models.CacheTargetTools:
+			if len(messageParams.Tools) > 0 {
+				cacheControl = messageParams.Tools[len(messageParams.Tools)-1].GetCacheControl()
+			}
+		case models.CacheTargetMessage:
+			if position, ok := positions[breakpoint.MessageIndex]; ok {
+				cacheControl = messageParams.Messages[position.message].Content[position.block].GetCacheControl()
+			}
+		}
+
+		// 対象が存在しない場合や、同じブロックへの重複した指定は無視します
+		if cacheControl == nil || cacheControl.Type != "" {
+			continue
+		}
+		cacheControl.Type = "ephemeral"
+		count++
+	}
+
+	if count > anthropicMaxCacheBreakpoints {
+		return fmt.Errorf("%w: Anthropic allows at most %d breakpoints, got %d", models.ErrInvalidCacheBreakpoint, anthropicMaxCacheBreakpoints, count)
+	}
+	return nil
+}
+
+// anthropicInputSchema は、JSON SchemaをAnthropic APIのツール入力スキーマに変換します。
+// ツールの入力は常にオブジェクトであるため、ルートがオブジェクトでないスキーマは ErrUnsupportedParameter を返します。
+func anthropicInputSchema(schema map[string]any) (anthropic.ToolInputSchemaParam, error) {
+	if typ, ok := schema["type"]; ok && typ != "object" {
+		return anthropic.ToolInputSchemaParam{}, fmt.Errorf("%w: Anthropic requires an object schema at the root, got type %v", models.ErrUnsupportedParameter, typ)
+	}
+
+	inputSchema := anthropic.ToolInputSchemaParam{}
+	for key, value := range schema {
+		switch key {
+		case "type":
+			// ルートは常に object として扱われます
+		case "properties":
+			inputSchema.Properties = value
+		default:
+			if inputSchema.ExtraFields == nil {
+				inputSchema.ExtraFields = map[string]any{}
+			}
+			inputSchema.ExtraFields[key] = value
+		}
+	}
+	return inputSchema, nil
+}
+
+// anthropicContentBlock は、メッセージのパートをAnthropic APIのコンテンツブロックに変換します。
+func anthropicContentBlock(part models.Part) anthropic.ContentBlockParamUnion {
+	switch part.Type {
+	case models.PartTypeImage:
+		source := anthropic.ImageBlockParamSourceUnion{}
+		if part.URL != "" {
+			source.OfURLImageSource = &anthropic.URLImageSourceParam{URL: part.URL}
+		} else {
+			source.OfBase64ImageSource = &anthropic.Base64ImageSourceParam{
+				Data:      part.Base64(),
+				MediaType: anthropic.Base64ImageSourceMediaType(part.MIMEType),
+			}
+		}
+		return anthropic.ContentBlockParamUnion{
+			OfRequestImageBlock: &anthropic.ImageBlockParam{Source: source},
+		}
+	case models.PartTypeDocument:
+		source := anthropic.DocumentBlockParamSourceUnion{}
+		if part.URL != "" {
+			source.OfUrlpdfSource = &anthropic.URLPDFSourceParam{URL: part.URL}
+		} else {
+			source.OfBase64PDFSource = &anthropic.Base64PDFSourceParam{Data: part.Base64()}
+		}
+		return anthropic.ContentBlockParamUnion{
+			OfRequestDocumentBlock: &anthropic.DocumentBlockParam{Source: source},
+		}
+	default:
+		return anthropic.ContentBlockParamUnion{
+			OfRequestTextBlock: &anthropic.TextBlockParam{Text: part.Text},
+		}
+	}
+}
+
+// anthropicResponse は、Anthropic APIのメッセージを共通のレスポンスに変換します。
+func anthropicResponse(message *anthropic.Message) *models.GenTextResponse {
+	var text strings.Builder
+	var toolCalls []models.ToolCall
+	for _, block := range message.Content {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "tool_use":
+			arguments := block.Input
+			if len(arguments) == 0 {
+				arguments = json.RawMessage("{}")
+			}
+			toolCalls = append(toolCalls, models.ToolCall{
+				ID:        block.ID,
+				Name:      block.Name,
+				Arguments: arguments,
+			})
+		}
+	}
+
+	// Anthropicの入力トークン数には、キャッシュの読み書きに使われたトークンが含まれないため合算します
+	usage := models.Usage{
+		InputTokens:         int(message.Usage.InputTokens + message.Usage.CacheReadInputTokens + message.Usage.CacheCreationInputTokens),
+		OutputTokens:        int(message.Usage.OutputTokens),
+		CachedInputTokens:   int(message.Usage.CacheReadInputTokens),
+		CacheCreationTokens: int(message.Usage.CacheCreationInputTokens),
+	}
+	usage.TotalTokens = usage.InputTokens + usage.OutputTokens
+
+	return &models.GenTextResponse{
+		Text:         text.String(),
+		ToolCalls:    toolCalls,
+		Tokens:       usage.TotalTokens,
+		Usage:        usage,
+		FinishReason: anthropicFinishReason(message.StopReason),
+		Provider:     models.ProviderAnthropic,
+		Model:        models.Model(message.Model),
+	}
+}
+
+// applyAnthropicResponseFormat は、構造化出力のために強制したツール呼び出しの引数をレスポンスのテキストに移します。
+func applyAnthropicResponseFormat(res *models.GenTextResponse, format *models.ResponseFormat) {
+	if format == nil {
+		return
+	}
+
+	name := responseFormatName(format)
+	for i, call := range res.ToolCalls {
+		if call.Name != name {
+			continue
+		}
+		res.Text = string(call.Arguments)
+		res.ToolCalls = append(res.ToolCalls[:i], res.ToolCalls[i+1:]...)
+		if res.FinishReason == models.FinishReasonToolUse {
+			res.FinishReason = models.FinishReasonStop
+		}
+		return
+	}
+}
+
+// anthropicFinishReason は、Anthropic APIの停止理由を共通の値に正規化します。
+func anthropicFinishReason(reason anthropic.MessageStopReason) models.FinishReason {
+	switch reason {
+	case anthropic.MessageStopReasonEndTurn, anthropic.MessageStopReasonStopSequence:
+		return models.FinishReasonStop
+	case anthropic.MessageStopReasonMaxTokens:
+		return models.FinishReasonLength
+	case anthropic.MessageStopReasonToolUse:
+		return models.FinishReasonToolUse
+	case "refusal":
+		return models.FinishReasonSafety
+	default:
+		return models.FinishReasonOther
+	}
 }
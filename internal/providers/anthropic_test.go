@@ -0,0 +1,39 @@
+package providers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/obutora/ai-wrapper/models"
+)
+
+func TestAnthropicInputSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  map[string]any
+		wantErr bool
+	}{
+		{name: "object", schema: map[string]any{"type": "object", "properties": map[string]any{"city": map[string]any{"type": "string"}}}},
+		{name: "no type", schema: map[string]any{"properties": map[string]any{}}},
+		{name: "array", schema: map[string]any{"type": "array", "items": map[string]any{"type": "string"}}, wantErr: true},
+		{name: "nullable object", schema: map[string]any{"type": []any{"object", "null"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputSchema, err := anthropicInputSchema(tt.schema)
+			if tt.wantErr {
+				if !errors.Is(err, models.ErrUnsupportedParameter) {
+					t.Errorf("anthropicInputSchema() error = %v, want ErrUnsupportedParameter", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("anthropicInputSchema() error = %v", err)
+			}
+			if inputSchema.Properties == nil {
+				t.Error("properties were dropped")
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package providers
+
+import (
+	"context"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/obutora/ai-wrapper/models"
+)
+
+// CountTokens は、Anthropic APIの count_tokens エンドポイントを使用して入力トークン数を計算します。
+func (c *AnthropicClient) CountTokens(ctx context.Context, params models.GenTextParams) (int, error) {
+	if err := validateParams(params); err != nil {
+		return 0, err
+	}
+
+	messageParams, err := c.buildParams(params)
+	if err != nil {
+		return 0, err
+	}
+
+	countParams := anthropic.MessageCountTokensParams{
+		Messages:   messageParams.Messages,
+		Model:      messageParams.Model,
+		ToolChoice: messageParams.ToolChoice,
+	}
+	if len(messageParams.System) > 0 {
+		countParams.System.OfMessageCountTokenssSystemArray = messageParams.System
+	}
+	for _, tool := range messageParams.Tools {
+		countParams.Tools = append(countParams.Tools, anthropic.MessageCountTokensToolUnionParam{
+			OfTool:               tool.OfTool,
+			OfBashTool20250124:   tool.OfBashTool20250124,
+			OfTextEditor20250124: tool.OfTextEditor20250124,
+		})
+	}
+
+	var res *anthropic.MessageTokensCount
+	_, err = withRetry(ctx, c.config.Retry, func() error {
+		var err error
+		res, err = c.client.Messages.CountTokens(ctx, countParams)
+		return err
+	})
+	if err != nil {
+		return 0, wrapAPIError(ctx, err)
+	}
+
+	return int(res.InputTokens), nil
+}
@@ -0,0 +1,245 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/http"
+
+	"github.com/obutora/ai-wrapper/models"
+)
+
+// validateParams は、全プロバイダに共通するパラメータの検証を行います。
+func validateParams(params models.GenTextParams) error {
+	if params.Model == "" {
+		return models.ErrInvalidModel
+	}
+
+	if len(params.Messages) == 0 && params.Prompt == "" {
+		return models.ErrEmptyMessages
+	}
+
+	// メッセージに対するキャッシュのブレークポイントが範囲内かを確認します
+	messageCount := len(params.Messages)
+	if messageCount == 0 {
+		messageCount = 1
+	}
+	for _, breakpoint := range params.CacheBreakpoints {
+		switch breakpoint.Target {
+		case models.CacheTargetSystem, models.CacheTargetTools:
+		case models.CacheTargetMessage:
+			if breakpoint.MessageIndex < 0 || breakpoint.MessageIndex >= messageCount {
+				return fmt.Errorf("%w: message index %d out of range", models.ErrInvalidCacheBreakpoint, breakpoint.MessageIndex)
+			}
+		default:
+			return fmt.Errorf("%w: unknown target %q", models.ErrInvalidCacheBreakpoint, breakpoint.Target)
+		}
+	}
+
+	// 画像やドキュメントを含む場合は、モデルが対応しているかを確認します
+	if !params.Model.SupportsVision() {
+		for _, msg := range params.Messages {
+			if msg.HasMedia() {
+				return fmt.Errorf("%w: %s", models.ErrVisionNotSupported, params.Model)
+			}
+		}
+	}
+
+	// レジストリに登録されているモデルは、対応している機能と出力トークン数の上限を確認します
+	if info, ok := params.Model.Info(); ok {
+		switch {
+		case info.Capabilities.Embedding:
+			return fmt.Errorf("%w: %s is an embedding model", models.ErrInvalidModel, params.Model)
+		case len(params.Tools) > 0 && !info.Capabilities.Tools:
+			return fmt.Errorf("%w: %s is not supported by %s", models.ErrUnsupportedParameter, paramTools, params.Model)
+		case params.ResponseFormat != nil && !info.Capabilities.JSONSchema:
+			return fmt.Errorf("%w: %s is not supported by %s", models.ErrUnsupportedParameter, paramResponseFormat, params.Model)
+		case info.MaxOutputTokens > 0 && params.MaxTokens > info.MaxOutputTokens:
+			return fmt.Errorf("%w: %s %d exceeds the limit of %s (%d)", models.ErrUnsupportedParameter, paramMaxTokens, params.MaxTokens, params.Model, info.MaxOutputTokens)
+		}
+	}
+
+	return nil
+}
+
+// validateStreamParams は、ストリーミングのパラメータの検証を行います。
+func validateStreamParams(params models.GenTextParams) error {
+	if err := validateParams(params); err != nil {
+		return err
+	}
+
+	if info, ok := params.Model.Info(); ok && !info.Capabilities.Streaming {
+		return fmt.Errorf("%w: streaming is not supported by %s", models.ErrUnsupportedParameter, params.Model)
+	}
+
+	return nil
+}
+
+// checkContextWindow は、Config.CheckContextWindow が有効な場合に、入力トークン数と最大出力トークン数の合計が
+// モデルのコンテキストウィンドウに収まるかを確認します。レジストリに登録されていないモデルは確認しません。
+func checkContextWindow(ctx context.Context, counter models.TokenCounter, params models.GenTextParams, config models.Config) error {
+	if !config.CheckContextWindow {
+		return nil
+	}
+	info, ok := params.Model.Info()
+	if !ok || info.ContextWindow == 0 {
+		return nil
+	}
+
+	input, err := counter.CountTokens(withoutAttemptHook(ctx), params)
+	if err != nil {
+		return err
+	}
+	output := int(maxTokens(params, config))
+	if input+output > info.ContextWindow {
+		return fmt.Errorf("%w: %d input tokens + %d max output tokens exceeds the context window of %s (%d)",
+			models.ErrContextLengthExceeded, input, output, params.Model, info.ContextWindow)
+	}
+	return nil
+}
+
+// validateEmbedParams は、埋め込みベクトル生成のパラメータの検証を行います。
+func validateEmbedParams(params models.EmbedParams) error {
+	if params.Model == "" {
+		return models.ErrInvalidModel
+	}
+
+	if len(params.Inputs) == 0 {
+		return models.ErrEmptyInputs
+	}
+
+	if info, ok := params.Model.Info(); ok && !info.Capabilities.Embedding {
+		return fmt.Errorf("%w: %s is not an embedding model", models.ErrEmbeddingNotSupported, params.Model)
+	}
+
+	return nil
+}
+
+// パラメータの名前
+const (
+	paramTemperature      = "temperature"
+	paramTopP             = "top_p"
+	paramTopK             = "top_k"
+	paramStopSequences    = "stop_sequences"
+	paramSeed             = "seed"
+	paramPresencePenalty  = "presence_penalty"
+	paramFrequencyPenalty = "frequency_penalty"
+	paramCachedContent    = "cached_content"
+	paramTools            = "tools"
+	paramResponseFormat   = "response_format"
+	paramMaxTokens        = "max_tokens"
+)
+
+// checkUnsupportedParams は、サポートされていないサンプリングパラメータが指定されている場合にエラーを返します。
+func checkUnsupportedParams(params models.GenTextParams, unsupported ...string) error {
+	specified := map[string]bool{
+		paramTemperature:      params.Temperature != nil,
+		paramTopP:             params.TopP != nil,
+		paramTopK:             params.TopK != nil,
+		paramStopSequences:    len(params.StopSequences) > 0,
+		paramSeed:             params.Seed != nil,
+		paramPresencePenalty:  params.PresencePenalty != nil,
+		paramFrequencyPenalty: params.FrequencyPenalty != nil,
+		paramCachedContent:    params.CachedContent != "",
+	}
+	for _, name := range unsupported {
+		if specified[name] {
+			return fmt.Errorf("%w: %s is not supported by %s", models.ErrUnsupportedParameter, name, params.Model)
+		}
+	}
+	return nil
+}
+
+// maxTokens は、リクエストで使用する最大トークン数を返します。
+// GenTextParams.MaxTokens が指定されていない場合は、Config.MaxToken を使用します。
+func maxTokens(params models.GenTextParams, config models.Config) int64 {
+	if params.MaxTokens > 0 {
+		return int64(params.MaxTokens)
+	}
+	return int64(config.MaxToken)
+}
+
+// float32Ptr は、float64のポインタをfloat32のポインタに変換します。
+func float32Ptr(v *float64) *float32 {
+	if v == nil {
+		return nil
+	}
+	f := float32(*v)
+	return &f
+}
+
+// systemTexts は、SystemPrompt とシステムメッセージのテキストを順に返します。
+// AnthropicやGeminiのように、システム指示を会話とは別に指定するプロバイダで使用します。
+func systemTexts(params models.GenTextParams) []string {
+	texts := []string{}
+	if params.SystemPrompt != "" {
+		texts = append(texts, params.SystemPrompt)
+	}
+	for _, msg := range params.Messages {
+		if msg.Role == models.RoleSystem {
+			if text := msg.Text(); text != "" {
+				texts = append(texts, text)
+			}
+		}
+	}
+	return texts
+}
+
+// defaultCacheBreakpoints は、CacheEnabled が有効でブレークポイントが未指定の場合の既定のブレークポイントを返します。
+// システムプロンプト、ツール定義、最後のメッセージの順に置きます。
+func defaultCacheBreakpoints(params models.GenTextParams) []models.CacheBreakpoint {
+	breakpoints := []models.CacheBreakpoint{}
+	if len(systemTexts(params)) > 0 {
+		breakpoints = append(breakpoints, models.CacheSystemPrompt())
+	}
+	if len(params.Tools) > 0 {
+		breakpoints = append(breakpoints, models.CacheTools())
+	}
+	if len(params.Messages) > 0 {
+		breakpoints = append(breakpoints, models.CacheMessage(len(params.Messages)-1))
+	} else if params.Prompt != "" {
+		breakpoints = append(breakpoints, models.CacheMessage(0))
+	}
+	return breakpoints
+}
+
+// cost は、Config の料金表を使用して、候補のモデル名を順に検索して使用量の料金を計算します。
+// レスポンスのモデル名（日付付きのことがあります）、リクエストのモデル名の順に指定します。
+func cost(config models.Config, usage models.Usage, candidates ...models.Model) *models.Cost {
+	catalog := config.Pricing
+	if catalog == nil {
+		catalog = models.DefaultPricingCatalog()
+	}
+	return catalog.Cost(usage, candidates...)
+}
+
+// errStream は、エラーのみを返すストリームを作成します。
+func errStream(err error) iter.Seq2[models.StreamEvent, error] {
+	return func(yield func(models.StreamEvent, error) bool) {
+		yield(models.StreamEvent{}, err)
+	}
+}
+
+// legacyResult は、GenTextResponse を従来の (string, error, int) 形式の戻り値に変換します。
+func legacyResult(res *models.GenTextResponse, err error) (string, error, int) {
+	if err != nil {
+		return "", err, 0
+	}
+	return res.Text, nil, res.Usage.TotalTokens
+}
+
+// requestID は、HTTPレスポンスのヘッダーからリクエストIDを取得します。
+func requestID(res *http.Response, header string) string {
+	if res == nil {
+		return ""
+	}
+	return res.Header.Get(header)
+}
+
+// responseFormatName は、構造化出力のスキーマ名を返します。未指定の場合は "response" を使用します。
+func responseFormatName(format *models.ResponseFormat) string {
+	if format.Name == "" {
+		return "response"
+	}
+	return format.Name
+}
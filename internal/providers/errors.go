@@ -0,0 +1,114 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/obutora/ai-wrapper/models"
+	"github.com/openai/openai-go"
+	"google.golang.org/genai"
+)
+
+// wrapAPIError は、SDKから返されたエラーを共通のエラー型でラップします。
+// コンテキストが終了している場合は、ErrRequestCanceled を返します。
+// プロバイダのAPIから返されたエラーは、詳細を含む *models.ProviderError に変換します。
+// 元のエラーもラップされるため、errors.As でSDKのエラー型を取得できます。
+func wrapAPIError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("%w: %w", models.ErrRequestCanceled, ctxErr)
+	}
+	if providerErr := newProviderError(err); providerErr != nil {
+		return providerErr
+	}
+	return fmt.Errorf("%w: %w", models.ErrAPIRequest, err)
+}
+
+// contentFilteredError は、プロバイダがコンテンツをブロックし、テキストを返さなかった場合のエラーを作成します。
+func contentFilteredError(reason string) error {
+	return fmt.Errorf("%w: %w: %s", models.ErrAPIRequest, models.ErrContentFiltered, reason)
+}
+
+// newProviderError は、各SDKのエラー型から ProviderError を作成します。
+// SDKのエラー型でない場合（ネットワークエラーなど）は nil を返します。
+func newProviderError(err error) *models.ProviderError {
+	var providerErr *models.ProviderError
+
+	var openAIErr *openai.Error
+	var anthropicErr *anthropic.Error
+	var geminiErr genai.APIError
+	switch {
+	case errors.As(err, &openAIErr):
+		providerErr = &models.ProviderError{
+			Provider:   models.ProviderOpenAI,
+			StatusCode: openAIErr.StatusCode,
+			Type:       openAIErr.Type,
+			Code:       openAIErr.Code,
+			Message:    openAIErr.Message,
+			RequestID:  requestID(openAIErr.Response, "x-request-id"),
+		}
+	case errors.As(err, &anthropicErr):
+		// Anthropicのエラーは、{"type": "error", "error": {"type": ..., "message": ...}} の形式です
+		var body struct {
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		_ = json.Unmarshal([]byte(anthropicErr.RawJSON()), &body)
+		providerErr = &models.ProviderError{
+			Provider:   models.ProviderAnthropic,
+			StatusCode: anthropicErr.StatusCode,
+			Type:       body.Error.Type,
+			Message:    body.Error.Message,
+			RequestID:  requestID(anthropicErr.Response, "request-id"),
+		}
+	case errors.As(err, &geminiErr):
+		providerErr = &models.ProviderError{
+			Provider:   models.ProviderGemini,
+			StatusCode: geminiErr.Code,
+			Type:       geminiErr.Status,
+			Message:    geminiErr.Message,
+		}
+	default:
+		return nil
+	}
+
+	providerErr.Retryable, providerErr.RetryAfter = retryInfo(err)
+	providerErr.Category = errorCategory(providerErr)
+	providerErr.Err = err
+	return providerErr
+}
+
+// errorCategory は、ステータスコードとプロバイダ固有のエラーの種類・コード・メッセージからエラーを分類します。
+func errorCategory(err *models.ProviderError) error {
+	message := strings.ToLower(err.Message)
+
+	switch {
+	// コンテキスト長の超過は 400 で返されるため、ステータスコードより先にコードとメッセージで判定します
+	case err.Code == "context_length_exceeded",
+		strings.Contains(message, "prompt is too long"),
+		strings.Contains(message, "maximum context length"),
+		strings.Contains(message, "exceeds the maximum number of tokens"):
+		return models.ErrContextLengthExceeded
+	case err.Code == "content_filter", err.Code == "content_policy_violation":
+		return models.ErrContentFiltered
+	case err.StatusCode == http.StatusUnauthorized, err.StatusCode == http.StatusForbidden,
+		err.Type == "authentication_error", err.Type == "permission_error",
+		err.Type == "UNAUTHENTICATED", err.Type == "PERMISSION_DENIED",
+		strings.Contains(message, "api key not valid"):
+		return models.ErrAuthentication
+	case err.StatusCode == http.StatusTooManyRequests,
+		err.Type == "rate_limit_error", err.Type == "RESOURCE_EXHAUSTED":
+		return models.ErrRateLimited
+	case err.StatusCode >= http.StatusInternalServerError,
+		err.Type == "overloaded_error", err.Type == "api_error", err.Type == "UNAVAILABLE":
+		return models.ErrProviderUnavailable
+	default:
+		return nil
+	}
+}
@@ -2,7 +2,13 @@ package providers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"iter"
+	"math"
+	"strings"
+	"time"
 
 	"github.com/obutora/ai-wrapper/models"
 	"google.golang.org/genai"
@@ -31,81 +37,526 @@ func NewGeminiClient(apiKey string, config models.Config) *GeminiClient {
 
 // GenText は、Gemini APIを使用してテキストを生成します。
 func (c *GeminiClient) GenText(params models.GenTextParams) (string, error, int) {
-	if params.Model == "" {
-		return "", models.ErrInvalidModel, 0
+	return c.GenTextContext(context.Background(), params)
+}
+
+// GenTextContext は、コンテキストを指定してGemini APIでテキストを生成します。
+// コンテキストのキャンセルやデッドラインはSDKの呼び出しに伝播されます。
+func (c *GeminiClient) GenTextContext(ctx context.Context, params models.GenTextParams) (string, error, int) {
+	return legacyResult(c.Generate(ctx, params))
+}
+
+// Generate は、Gemini APIを使用してテキストを生成し、詳細なレスポンスを返します。
+func (c *GeminiClient) Generate(ctx context.Context, params models.GenTextParams) (*models.GenTextResponse, error) {
+	return instrumentGenerate(ctx, c.config, models.ProviderGemini, params, c.generate)
+}
+
+// generate は、Gemini APIを呼び出してテキストを生成します。
+func (c *GeminiClient) generate(ctx context.Context, params models.GenTextParams) (*models.GenTextResponse, error) {
+	if err := validateParams(params); err != nil {
+		return nil, err
+	}
+	if err := checkContextWindow(ctx, c, params, c.config); err != nil {
+		return nil, err
 	}
 
-	if len(params.Messages) == 0 && params.Prompt == "" {
-		return "", models.ErrEmptyMessages, 0
+	contents, conf, err := c.buildRequest(params)
+	if err != nil {
+		return nil, err
 	}
 
-	ctx := context.Background()
+	// APIリクエストを実行
+	var res *genai.GenerateContentResponse
+	start := time.Now()
+	attempts, err := withRetry(ctx, c.config.Retry, func() error {
+		var err error
+		res, err = c.client.Models.GenerateContent(ctx, params.Model.APIID(), contents, conf)
+		return err
+	})
+	if err != nil {
+		return nil, wrapGeminiError(ctx, params, err)
+	}
+	latency := time.Since(start)
 
-	// メッセージを変換
-	history := []*genai.Content{}
-	if len(params.Messages) > 0 {
-		for _, msg := range params.Messages {
-			var role genai.Role
-			switch msg.Role {
-			case models.RoleUser:
-				role = genai.RoleUser
-			case models.RoleAssistant:
-				role = genai.RoleModel
-			case models.RoleSystem:
-				// Geminiでは、システムメッセージはユーザーメッセージとして扱います
-				role = genai.RoleUser
-			default:
-				role = genai.RoleUser
+	// レスポンスからテキストを取得
+	// プロンプトまたは応答が安全性の理由でブロックされた場合は、候補やテキストが返されません
+	if res.PromptFeedback != nil && res.PromptFeedback.BlockReason != "" {
+		return nil, contentFilteredError("prompt blocked: " + string(res.PromptFeedback.BlockReason))
+	}
+	if len(res.Candidates) == 0 || res.Candidates[0].Content == nil || len(res.Candidates[0].Content.Parts) == 0 {
+		if len(res.Candidates) > 0 && geminiFinishReason(res.Candidates[0].FinishReason) == models.FinishReasonSafety {
+			return nil, contentFilteredError("response blocked: " + string(res.Candidates[0].FinishReason))
+		}
+		return nil, wrapAPIError(ctx, errors.New("no content returned"))
+	}
+
+	response := &models.GenTextResponse{
+		Provider: models.ProviderGemini,
+		Model:    params.Model,
+	}
+	mergeGeminiResponse(response, res)
+	response.Text = geminiText(res.Candidates[0])
+	response.ToolCalls = geminiToolCalls(res.Candidates[0])
+	if len(response.ToolCalls) > 0 {
+		response.FinishReason = models.FinishReasonToolUse
+	}
+	response.Latency = latency
+	response.Attempts = attempts
+	response.Cost = cost(c.config, response.Usage, response.Model, params.Model)
+
+	return response, nil
+}
+
+// GenTextStream は、Gemini APIのストリーミングを使用してテキストを逐次生成します。
+// 最終イベントには、終了理由とトークン使用量が含まれます。
+func (c *GeminiClient) GenTextStream(ctx context.Context, params models.GenTextParams) iter.Seq2[models.StreamEvent, error] {
+	return instrumentStream(ctx, c.config, models.ProviderGemini, params, c.genTextStream)
+}
+
+// genTextStream は、Gemini APIのストリーミングを呼び出してテキストを逐次生成します。
+func (c *GeminiClient) genTextStream(ctx context.Context, params models.GenTextParams) iter.Seq2[models.StreamEvent, error] {
+	if err := validateStreamParams(params); err != nil {
+		return errStream(err)
+	}
+	if err := checkContextWindow(ctx, c, params, c.config); err != nil {
+		return errStream(err)
+	}
+
+	contents, conf, err := c.buildRequest(params)
+	if err != nil {
+		return errStream(err)
+	}
+
+	return retryStream(ctx, c.config.Retry, func(yield func(models.StreamEvent, error) bool) {
+		start := time.Now()
+		response := &models.GenTextResponse{
+			Provider: models.ProviderGemini,
+			Model:    params.Model,
+		}
+		var text strings.Builder
+		for res, err := range c.client.Models.GenerateContentStream(ctx, params.Model.APIID(), contents, conf) {
+			if err != nil {
+				yield(models.StreamEvent{}, wrapGeminiError(ctx, params, err))
+				return
+			}
+
+			mergeGeminiResponse(response, res)
+			if len(res.Candidates) == 0 {
+				continue
 			}
 
-			content := genai.NewContentFromText(msg.Content, role)
-			history = append(history, content)
+			response.ToolCalls = append(response.ToolCalls, geminiToolCalls(res.Candidates[0])...)
+			delta := geminiText(res.Candidates[0])
+			if delta == "" {
+				continue
+			}
+			text.WriteString(delta)
+			if !yield(models.StreamEvent{Delta: delta}, nil) {
+				return
+			}
 		}
+
+		response.Text = text.String()
+		if len(response.ToolCalls) > 0 {
+			response.FinishReason = models.FinishReasonToolUse
+		}
+		response.Latency = time.Since(start)
+		response.Cost = cost(c.config, response.Usage, response.Model, params.Model)
+
+		yield(models.StreamEvent{
+			Done:         true,
+			FinishReason: response.FinishReason,
+			Usage:        response.Usage,
+			Response:     response,
+		}, nil)
+	})
+}
+
+// wrapGeminiError は、Gemini APIのエラーを共通のエラーに変換します。
+// コンテキストキャッシュを参照している場合は、キャッシュが見つからないエラーを ErrCacheNotFound として返します。
+func wrapGeminiError(ctx context.Context, params models.GenTextParams, err error) error {
+	if params.CachedContent != "" {
+		return wrapGeminiCacheError(ctx, params.CachedContent, err)
+	}
+	return wrapAPIError(ctx, err)
+}
+
+// buildRequest は、共通パラメータをGemini APIのリクエスト（コンテンツと設定）に変換します。
+// 会話履歴は、最後のメッセージまで含めてそのままの順序で送信します。
+func (c *GeminiClient) buildRequest(params models.GenTextParams) ([]*genai.Content, *genai.GenerateContentConfig, error) {
+	// メッセージを変換
+	contents := []*genai.Content{}
+	if len(params.Messages) > 0 {
+		contents = geminiContents(params.Messages)
+	} else if params.Prompt != "" {
+		// プロンプトがある場合は、ユーザーメッセージとして追加します
+		contents = append(contents, genai.NewContentFromText(params.Prompt, genai.RoleUser))
+	}
+
+	// メッセージがない場合は、エラーを返します
+	if len(contents) == 0 {
+		return nil, nil, models.ErrEmptyMessages
 	}
 
 	conf := &genai.GenerateContentConfig{
-		MaxOutputTokens: int32(c.config.MaxToken),
+		MaxOutputTokens:  int32(maxTokens(params, c.config)),
+		Temperature:      float32Ptr(params.Temperature),
+		TopP:             float32Ptr(params.TopP),
+		StopSequences:    params.StopSequences,
+		PresencePenalty:  float32Ptr(params.PresencePenalty),
+		FrequencyPenalty: float32Ptr(params.FrequencyPenalty),
+	}
+	conf.SystemInstruction = geminiSystemInstruction(systemTexts(params))
+	if params.TopK != nil {
+		topK := float32(*params.TopK)
+		conf.TopK = &topK
+	}
+	if params.Seed != nil {
+		// Gemini APIのシードは32ビット整数のため、範囲外の値は切り詰めずにエラーを返します
+		if *params.Seed < math.MinInt32 || *params.Seed > math.MaxInt32 {
+			return nil, nil, fmt.Errorf("%w: %s %d is out of the int32 range supported by Gemini", models.ErrUnsupportedParameter, paramSeed, *params.Seed)
+		}
+		seed := int32(*params.Seed)
+		conf.Seed = &seed
 	}
 
-	// チャットセッションを作成
-	chat, err := c.client.Chats.Create(ctx, string(params.Model), conf, history)
-	if err != nil {
-		return "", fmt.Errorf("%w: %v", models.ErrAPIRequest, err), 0
-	}
-
-	// メッセージを送信
-	var message string
-	if params.Prompt != "" {
-		message = params.Prompt
-	} else if len(params.Messages) > 0 {
-		// 最後のユーザーメッセージを使用
-		for i := len(params.Messages) - 1; i >= 0; i-- {
-			if params.Messages[i].Role == models.RoleUser {
-				message = params.Messages[i].Content
-				break
+	// ツールを設定
+	conf.Tools = geminiTools(params.Tools)
+	switch params.ToolChoice {
+	case "":
+	case models.ToolChoiceAuto:
+		conf.ToolConfig = geminiToolConfig(genai.FunctionCallingConfigModeAuto)
+	case models.ToolChoiceNone:
+		conf.ToolConfig = geminiToolConfig(genai.FunctionCallingConfigModeNone)
+	case models.ToolChoiceRequired:
+		conf.ToolConfig = geminiToolConfig(genai.FunctionCallingConfigModeAny)
+	default:
+		conf.ToolConfig = geminiToolConfig(genai.FunctionCallingConfigModeAny, string(params.ToolChoice))
+	}
+
+	// 構造化出力を設定
+	if format := params.ResponseFormat; format != nil {
+		conf.ResponseMIMEType = "application/json"
+		conf.ResponseSchema = geminiSchema(format.Schema)
+	}
+
+	// コンテキストキャッシュを参照する場合、システム指示とツールはキャッシュに含める必要があります
+	if params.CachedContent != "" {
+		if conf.SystemInstruction != nil || len(conf.Tools) > 0 {
+			return nil, nil, fmt.Errorf("%w: system instructions and tools must be set on the cached content", models.ErrUnsupportedParameter)
+		}
+		conf.CachedContent = params.CachedContent
+	}
+
+	return contents, conf, nil
+}
+
+// geminiContents は、会話履歴をGemini APIのコンテンツに変換します。
+// システムメッセージは除外され、並列で呼び出されたツールの結果は1つのコンテンツにまとめられます。
+func geminiContents(messages []models.Message) []*genai.Content {
+	contents := []*genai.Content{}
+	for i, msg := range messages {
+		// システムメッセージは、SystemInstruction で送信します
+		if msg.Role == models.RoleSystem {
+			continue
+		}
+
+		content := geminiContent(msg)
+		if msg.Role == models.RoleTool && i > 0 && messages[i-1].Role == models.RoleTool && len(contents) > 0 {
+			last := contents[len(contents)-1]
+			last.Parts = append(last.Parts, content.Parts...)
+			continue
+		}
+		contents = append(contents, content)
+	}
+	return contents
+}
+
+// geminiSystemInstruction は、システム指示のテキストをGemini APIのコンテンツに変換します。
+func geminiSystemInstruction(texts []string) *genai.Content {
+	if len(texts) == 0 {
+		return nil
+	}
+	instruction := &genai.Content{}
+	for _, text := range texts {
+		instruction.Parts = append(instruction.Parts, &genai.Part{Text: text})
+	}
+	return instruction
+}
+
+// geminiTools は、ツールの定義をGemini APIの関数宣言に変換します。
+func geminiTools(tools []models.Tool) []*genai.Tool {
+	if len(tools) == 0 {
+		return nil
+	}
+	declarations := []*genai.FunctionDeclaration{}
+	for _, tool := range tools {
+		declarations = append(declarations, &genai.FunctionDeclaration{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Parameters:  geminiSchema(tool.Parameters),
+		})
+	}
+	return []*genai.Tool{{FunctionDeclarations: declarations}}
+}
+
+// geminiContent は、共通のメッセージをGemini APIのコンテンツに変換します。
+func geminiContent(msg models.Message) *genai.Content {
+	switch msg.Role {
+	case models.RoleAssistant:
+		content := &genai.Content{Role: genai.RoleModel}
+		if msg.Content != "" || len(msg.ToolCalls) == 0 {
+			content.Parts = append(content.Parts, &genai.Part{Text: msg.Content})
+		}
+		for _, call := range msg.ToolCalls {
+			args := map[string]any{}
+			if len(call.Arguments) > 0 {
+				// 引数がJSONオブジェクトでない場合は、空の引数として扱います
+				_ = json.Unmarshal(call.Arguments, &args)
 			}
+			content.Parts = append(content.Parts, &genai.Part{
+				FunctionCall: &genai.FunctionCall{ID: call.ID, Name: call.Name, Args: args},
+			})
+		}
+		return content
+	case models.RoleTool:
+		// ツールの実行結果は、FunctionResponse としてユーザーロールで送信します
+		// 結果がJSONオブジェクトでない場合は、output キーに格納します
+		response := map[string]any{}
+		if err := json.Unmarshal([]byte(msg.Content), &response); err != nil {
+			response = map[string]any{"output": msg.Content}
+		}
+		return &genai.Content{
+			Role: genai.RoleUser,
+			Parts: []*genai.Part{{
+				FunctionResponse: &genai.FunctionResponse{ID: msg.ToolCallID, Name: msg.ToolName, Response: response},
+			}},
+		}
+	default:
+		if len(msg.Parts) == 0 {
+			return genai.NewContentFromText(msg.Content, genai.RoleUser)
+		}
+		content := &genai.Content{Role: genai.RoleUser}
+		for _, part := range msg.ContentParts() {
+			content.Parts = append(content.Parts, geminiPart(part))
 		}
+		return content
 	}
+}
 
-	// メッセージがない場合は、エラーを返します
-	if message == "" {
-		return "", models.ErrEmptyMessages, 0
+// geminiPart は、メッセージのパートをGemini APIのパートに変換します。
+// URLで指定された画像やドキュメントは FileData として、バイト列は InlineData として送信します。
+func geminiPart(part models.Part) *genai.Part {
+	switch {
+	case part.Type == models.PartTypeText:
+		return &genai.Part{Text: part.Text}
+	case part.URL != "":
+		return &genai.Part{FileData: &genai.FileData{FileURI: part.URL, MIMEType: part.MIMEType}}
+	default:
+		return &genai.Part{InlineData: &genai.Blob{Data: part.Data, MIMEType: part.MIMEType}}
 	}
+}
 
-	// APIリクエストを実行
-	res, err := chat.SendMessage(ctx, genai.Part{Text: message})
-	if err != nil {
-		return "", fmt.Errorf("%w: %v", models.ErrAPIRequest, err), 0
+// geminiToolConfig は、関数呼び出しモードを指定したツール設定を作成します。
+func geminiToolConfig(mode genai.FunctionCallingConfigMode, allowed ...string) *genai.ToolConfig {
+	return &genai.ToolConfig{
+		FunctionCallingConfig: &genai.FunctionCallingConfig{
+			Mode:                 mode,
+			AllowedFunctionNames: allowed,
+		},
 	}
+}
 
-	// レスポンスからテキストを取得
-	if len(res.Candidates) == 0 || len(res.Candidates[0].Content.Parts) == 0 {
-		return "", fmt.Errorf("no content returned"), 0
+// geminiText は、候補に含まれるテキストパートを連結して返します。
+// 思考（thought）パートは含めません。
+func geminiText(candidate *genai.Candidate) string {
+	if candidate.Content == nil {
+		return ""
+	}
+
+	var text strings.Builder
+	for _, part := range candidate.Content.Parts {
+		if part.Thought {
+			continue
+		}
+		text.WriteString(part.Text)
+	}
+	return text.String()
+}
+
+// geminiToolCalls は、候補に含まれる関数呼び出しをツール呼び出しに変換します。
+// GeminiがIDを返さない場合は、関数名をIDとして使用します。
+func geminiToolCalls(candidate *genai.Candidate) []models.ToolCall {
+	if candidate.Content == nil {
+		return nil
+	}
+
+	var calls []models.ToolCall
+	for _, part := range candidate.Content.Parts {
+		if part.FunctionCall == nil {
+			continue
+		}
+		arguments, err := json.Marshal(part.FunctionCall.Args)
+		if err != nil || part.FunctionCall.Args == nil {
+			arguments = []byte("{}")
+		}
+		id := part.FunctionCall.ID
+		if id == "" {
+			id = part.FunctionCall.Name
+		}
+		calls = append(calls, models.ToolCall{
+			ID:        id,
+			Name:      part.FunctionCall.Name,
+			Arguments: arguments,
+		})
+	}
+	return calls
+}
+
+// mergeGeminiResponse は、Gemini APIのレスポンスに含まれるメタデータを共通のレスポンスに反映します。
+// ストリーミング時は、チャンクごとに呼び出して最新の値で上書きします。
+func mergeGeminiResponse(response *models.GenTextResponse, res *genai.GenerateContentResponse) {
+	if res.ModelVersion != "" {
+		response.Model = models.Model(res.ModelVersion)
+	}
+	if res.ResponseID != "" {
+		response.RequestID = res.ResponseID
+	}
+	if res.UsageMetadata != nil {
+		// 他のプロバイダと揃えるため、出力トークン数には推論トークンを含めます
+		response.Usage = models.Usage{
+			InputTokens:       int(res.UsageMetadata.PromptTokenCount),
+			OutputTokens:      int(res.UsageMetadata.CandidatesTokenCount + res.UsageMetadata.ThoughtsTokenCount),
+			CachedInputTokens: int(res.UsageMetadata.CachedContentTokenCount),
+			ReasoningTokens:   int(res.UsageMetadata.ThoughtsTokenCount),
+			TotalTokens:       int(res.UsageMetadata.TotalTokenCount),
+		}
+		response.Tokens = response.Usage.TotalTokens
+	}
+	if len(res.Candidates) > 0 && res.Candidates[0].FinishReason != "" {
+		response.FinishReason = geminiFinishReason(res.Candidates[0].FinishReason)
+	}
+}
+
+// geminiFinishReason は、Gemini APIの終了理由を共通の値に正規化します。
+func geminiFinishReason(reason genai.FinishReason) models.FinishReason {
+	switch reason {
+	case genai.FinishReasonStop:
+		return models.FinishReasonStop
+	case genai.FinishReasonMaxTokens:
+		return models.FinishReasonLength
+	case genai.FinishReasonSafety, genai.FinishReasonRecitation, genai.FinishReasonBlocklist,
+		genai.FinishReasonProhibitedContent, genai.FinishReasonSPII, genai.FinishReasonImageSafety:
+		return models.FinishReasonSafety
+	default:
+		return models.FinishReasonOther
+	}
+}
+
+// geminiSchema は、JSON SchemaをGemini APIのスキーマに変換します。
+// Geminiがサポートしていないキーワードは無視されます。
+func geminiSchema(schema map[string]any) *genai.Schema {
+	if schema == nil {
+		return nil
 	}
 
-	text := res.Candidates[0].Content.Parts[0].Text
+	result := &genai.Schema{}
+	switch t := schema["type"].(type) {
+	case string:
+		result.Type = genai.Type(strings.ToUpper(t))
+	case []any:
+		// ["string", "null"] のような型指定は、nullable として扱います
+		for _, v := range t {
+			if name, ok := v.(string); ok {
+				if name == "null" {
+					nullable := true
+					result.Nullable = &nullable
+				} else {
+					result.Type = genai.Type(strings.ToUpper(name))
+				}
+			}
+		}
+	}
+	if v, ok := schema["description"].(string); ok {
+		result.Description = v
+	}
+	if v, ok := schema["title"].(string); ok {
+		result.Title = v
+	}
+	if v, ok := schema["format"].(string); ok {
+		result.Format = v
+	}
+	if v, ok := schema["pattern"].(string); ok {
+		result.Pattern = v
+	}
+	if v, ok := schema["nullable"].(bool); ok {
+		result.Nullable = &v
+	}
+	result.Enum = stringSlice(schema["enum"])
+	result.Required = stringSlice(schema["required"])
+	if properties, ok := schema["properties"].(map[string]any); ok {
+		result.Properties = map[string]*genai.Schema{}
+		for name, property := range properties {
+			if p, ok := property.(map[string]any); ok {
+				result.Properties[name] = geminiSchema(p)
+			}
+		}
+	}
+	if items, ok := schema["items"].(map[string]any); ok {
+		result.Items = geminiSchema(items)
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		for _, item := range anyOf {
+			if s, ok := item.(map[string]any); ok {
+				result.AnyOf = append(result.AnyOf, geminiSchema(s))
+			}
+		}
+	}
+	if v, ok := toFloat(schema["minimum"]); ok {
+		result.Minimum = &v
+	}
+	if v, ok := toFloat(schema["maximum"]); ok {
+		result.Maximum = &v
+	}
+	if v, ok := toFloat(schema["minItems"]); ok {
+		n := int64(v)
+		result.MinItems = &n
+	}
+	if v, ok := toFloat(schema["maxItems"]); ok {
+		n := int64(v)
+		result.MaxItems = &n
+	}
+	return result
+}
 
-	tokens := int(res.UsageMetadata.TotalTokenCount)
+// stringSlice は、[]any または []string を []string に変換します。
+func stringSlice(v any) []string {
+	switch values := v.(type) {
+	case []string:
+		return values
+	case []any:
+		result := make([]string, 0, len(values))
+		for _, value := range values {
+			result = append(result, fmt.Sprint(value))
+		}
+		return result
+	default:
+		return nil
+	}
+}
 
-	return text, nil, tokens
+// toFloat は、JSONの数値を float64 に変換します。
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	default:
+		return 0, false
+	}
 }
@@ -0,0 +1,87 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/obutora/ai-wrapper/models"
+	"google.golang.org/genai"
+)
+
+// CreateCache は、Gemini APIのコンテキストキャッシュを作成します。
+func (c *GeminiClient) CreateCache(ctx context.Context, params models.CachedContentParams) (*models.CachedContent, error) {
+	if params.Model == "" {
+		return nil, models.ErrInvalidModel
+	}
+	if len(params.Messages) == 0 && params.SystemPrompt == "" {
+		return nil, models.ErrEmptyMessages
+	}
+
+	systemParams := models.GenTextParams{SystemPrompt: params.SystemPrompt, Messages: params.Messages}
+	conf := &genai.CreateCachedContentConfig{
+		TTL:               params.TTL,
+		DisplayName:       params.DisplayName,
+		Contents:          geminiContents(params.Messages),
+		SystemInstruction: geminiSystemInstruction(systemTexts(systemParams)),
+		Tools:             geminiTools(params.Tools),
+	}
+
+	cache, err := c.client.Caches.Create(ctx, params.Model.APIID(), conf)
+	if err != nil {
+		return nil, wrapAPIError(ctx, err)
+	}
+
+	result := geminiCachedContent(cache)
+	return &result, nil
+}
+
+// ListCaches は、Gemini APIのコンテキストキャッシュの一覧を返します。
+func (c *GeminiClient) ListCaches(ctx context.Context) ([]models.CachedContent, error) {
+	caches := []models.CachedContent{}
+	for cache, err := range c.client.Caches.All(ctx) {
+		if err != nil {
+			return nil, wrapAPIError(ctx, err)
+		}
+		caches = append(caches, geminiCachedContent(cache))
+	}
+	return caches, nil
+}
+
+// DeleteCache は、Gemini APIのコンテキストキャッシュを削除します。
+func (c *GeminiClient) DeleteCache(ctx context.Context, name string) error {
+	if _, err := c.client.Caches.Delete(ctx, name, nil); err != nil {
+		return wrapGeminiCacheError(ctx, name, err)
+	}
+	return nil
+}
+
+// geminiCachedContent は、Gemini APIのキャッシュ情報を共通の形式に変換します。
+func geminiCachedContent(cache *genai.CachedContent) models.CachedContent {
+	result := models.CachedContent{
+		Name:        cache.Name,
+		DisplayName: cache.DisplayName,
+		Model:       models.Model(strings.TrimPrefix(cache.Model, "models/")),
+		CreateTime:  cache.CreateTime,
+		UpdateTime:  cache.UpdateTime,
+		ExpireTime:  cache.ExpireTime,
+	}
+	if cache.UsageMetadata != nil {
+		result.TokenCount = int(cache.UsageMetadata.TotalTokenCount)
+	}
+	return result
+}
+
+// wrapGeminiCacheError は、キャッシュが存在しない（または期限切れの）場合のエラーを ErrCacheNotFound でラップします。
+// モデルが存在しない場合なども 404 になるため、メッセージがキャッシュ（cachedContents）に言及する 404 のみを対象とし、
+// それ以外のエラーは通常のエラーとして扱います。
+func wrapGeminiCacheError(ctx context.Context, name string, err error) error {
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound &&
+		strings.Contains(strings.ToLower(apiErr.Message), "cachedcontent") {
+		return fmt.Errorf("%w: %s: %w", models.ErrCacheNotFound, name, wrapAPIError(ctx, err))
+	}
+	return wrapAPIError(ctx, err)
+}
@@ -0,0 +1,50 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/obutora/ai-wrapper/models"
+	"google.golang.org/genai"
+)
+
+func TestWrapGeminiCacheError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		notFound bool
+	}{
+		{
+			name:     "cache not found",
+			err:      genai.APIError{Code: 404, Message: "CachedContent not found (or permission denied)", Status: "NOT_FOUND"},
+			notFound: true,
+		},
+		{
+			name:     "cache resource name",
+			err:      genai.APIError{Code: 404, Message: "cachedContents/abc123 is not found", Status: "NOT_FOUND"},
+			notFound: true,
+		},
+		{
+			name: "model not found",
+			err:  genai.APIError{Code: 404, Message: "models/gemini-unknown is not found for API version v1beta", Status: "NOT_FOUND"},
+		},
+		{
+			name: "permission denied",
+			err:  genai.APIError{Code: 403, Message: "Permission denied on resource project", Status: "PERMISSION_DENIED"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := wrapGeminiCacheError(context.Background(), "cachedContents/abc123", tt.err)
+			if got := errors.Is(err, models.ErrCacheNotFound); got != tt.notFound {
+				t.Errorf("errors.Is(err, ErrCacheNotFound) = %v, want %v (err = %v)", got, tt.notFound, err)
+			}
+			var providerErr *models.ProviderError
+			if !errors.As(err, &providerErr) {
+				t.Errorf("err = %v, want *ProviderError", err)
+			}
+		})
+	}
+}
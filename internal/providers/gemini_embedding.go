@@ -0,0 +1,67 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/obutora/ai-wrapper/models"
+	"google.golang.org/genai"
+)
+
+// geminiEmbeddingBatchSize は、Geminiの埋め込みAPIに1回のリクエストで送信できる入力の最大数です。
+const geminiEmbeddingBatchSize = 100
+
+// Embed は、Gemini APIを使用して埋め込みベクトルを生成します。
+// 入力が上限を超える場合は、複数のリクエストに分割して送信します。
+func (c *GeminiClient) Embed(ctx context.Context, params models.EmbedParams) (*models.EmbedResponse, error) {
+	if err := validateEmbedParams(params); err != nil {
+		return nil, err
+	}
+
+	conf := &genai.EmbedContentConfig{}
+	if params.Dimensions > 0 {
+		dimensions := int32(params.Dimensions)
+		conf.OutputDimensionality = &dimensions
+	}
+
+	res := &models.EmbedResponse{
+		Embeddings: make([][]float32, 0, len(params.Inputs)),
+		Provider:   models.ProviderGemini,
+		Model:      params.Model,
+	}
+	for batch := range slices.Chunk(params.Inputs, geminiEmbeddingBatchSize) {
+		contents := make([]*genai.Content, len(batch))
+		for i, input := range batch {
+			contents[i] = genai.NewContentFromText(input, genai.RoleUser)
+		}
+
+		var embedding *genai.EmbedContentResponse
+		_, err := withRetry(ctx, c.config.Retry, func() error {
+			var err error
+			embedding, err = c.client.Models.EmbedContent(ctx, params.Model.APIID(), contents, conf)
+			return err
+		})
+		if err != nil {
+			return nil, wrapAPIError(ctx, err)
+		}
+		if len(embedding.Embeddings) != len(batch) {
+			return nil, fmt.Errorf("unexpected number of embeddings: got %d, want %d", len(embedding.Embeddings), len(batch))
+		}
+
+		for _, e := range embedding.Embeddings {
+			res.Embeddings = append(res.Embeddings, e.Values)
+			// トークン数は、Vertex AIでのみ返されます
+			if e.Statistics != nil {
+				res.Usage.InputTokens += int(e.Statistics.TokenCount)
+				res.Usage.TotalTokens += int(e.Statistics.TokenCount)
+			}
+		}
+	}
+
+	// トークン数が返されない場合は料金を計算できないため、Cost を nil のままにします
+	if res.Usage.TotalTokens > 0 {
+		res.Cost = cost(c.config, res.Usage, res.Model, params.Model)
+	}
+	return res, nil
+}
@@ -0,0 +1,42 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/obutora/ai-wrapper/models"
+	"google.golang.org/genai"
+)
+
+func TestGeminiEmbedWithoutUsage(t *testing.T) {
+	// Gemini API（Vertex AI以外）は、トークン数を返しません
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"embeddings":[{"values":[0.1,0.2]}]}`))
+	}))
+	defer server.Close()
+
+	client, err := genai.NewClient(context.Background(), &genai.ClientConfig{
+		APIKey:      "test-key",
+		Backend:     genai.BackendGeminiAPI,
+		HTTPOptions: genai.HTTPOptions{BaseURL: server.URL},
+	})
+	if err != nil {
+		t.Fatalf("genai.NewClient() error = %v", err)
+	}
+	c := &GeminiClient{client: client}
+
+	res, err := c.Embed(context.Background(), models.EmbedParams{Model: models.ModelGeminiEmbedding001, Inputs: []string{"hello"}})
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if res.Usage.TotalTokens != 0 {
+		t.Errorf("Usage.TotalTokens = %d, want 0", res.Usage.TotalTokens)
+	}
+	// 料金が設定されたモデルでも、使用量が不明な場合は $0 ではなく nil を返します
+	if res.Cost != nil {
+		t.Errorf("Cost = %+v, want nil when the token count is unknown", res.Cost)
+	}
+}
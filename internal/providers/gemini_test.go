@@ -0,0 +1,41 @@
+package providers
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/obutora/ai-wrapper/models"
+)
+
+func TestGeminiBuildRequestSeed(t *testing.T) {
+	tests := []struct {
+		name    string
+		seed    int64
+		wantErr bool
+	}{
+		{name: "in range", seed: 42},
+		{name: "int32 max", seed: math.MaxInt32},
+		{name: "int32 min", seed: math.MinInt32},
+		{name: "above int32", seed: math.MaxInt32 + 1, wantErr: true},
+		{name: "below int32", seed: math.MinInt32 - 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &GeminiClient{}
+			_, conf, err := c.buildRequest(models.GenTextParams{Model: models.ModelGemini20Flash, Prompt: "hi", Seed: &tt.seed})
+			if tt.wantErr {
+				if !errors.Is(err, models.ErrUnsupportedParameter) {
+					t.Fatalf("buildRequest() error = %v, want ErrUnsupportedParameter", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("buildRequest() error = %v", err)
+			}
+			if conf.Seed == nil || int64(*conf.Seed) != tt.seed {
+				t.Errorf("Seed = %v, want %d", conf.Seed, tt.seed)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/obutora/ai-wrapper/models"
+	"google.golang.org/genai"
+)
+
+// CountTokens は、Gemini APIの CountTokens を使用して入力トークン数を計算します。
+// Gemini API（Vertex AI以外）はシステム指示とツール定義を指定できないため、それらはテキストとして入力に含めて概算します。
+// CachedContent のトークン数は含まれません。
+func (c *GeminiClient) CountTokens(ctx context.Context, params models.GenTextParams) (int, error) {
+	if err := validateParams(params); err != nil {
+		return 0, err
+	}
+
+	contents, conf, err := c.buildRequest(params)
+	if err != nil {
+		return 0, err
+	}
+
+	countConf := &genai.CountTokensConfig{}
+	if c.client.ClientConfig().Backend == genai.BackendVertexAI {
+		countConf.SystemInstruction = conf.SystemInstruction
+		countConf.Tools = conf.Tools
+	} else {
+		extra := []*genai.Part{}
+		if conf.SystemInstruction != nil {
+			extra = append(extra, conf.SystemInstruction.Parts...)
+		}
+		if len(conf.Tools) > 0 {
+			declarations, err := json.Marshal(conf.Tools)
+			if err != nil {
+				return 0, fmt.Errorf("failed to marshal tools: %w", err)
+			}
+			extra = append(extra, genai.NewPartFromText(string(declarations)))
+		}
+		if len(extra) > 0 {
+			contents = append([]*genai.Content{{Role: genai.RoleUser, Parts: extra}}, contents...)
+		}
+	}
+
+	var res *genai.CountTokensResponse
+	_, err = withRetry(ctx, c.config.Retry, func() error {
+		var err error
+		res, err = c.client.Models.CountTokens(ctx, params.Model.APIID(), contents, countConf)
+		return err
+	})
+	if err != nil {
+		return 0, wrapGeminiError(ctx, params, err)
+	}
+
+	return int(res.TotalTokens), nil
+}
@@ -0,0 +1,103 @@
+package providers
+
+import (
+	"context"
+	"iter"
+	"time"
+
+	"github.com/obutora/ai-wrapper/internal/telemetry"
+	"github.com/obutora/ai-wrapper/models"
+)
+
+// メトリクスの status の値（失敗した場合は telemetry.ErrorType の値）
+const (
+	statusOK       = "ok"
+	statusCanceled = "canceled"
+)
+
+// instrumentGenerate は、テキスト生成のスパンを作成して generate を実行し、結果をメトリクスに記録します。
+func instrumentGenerate(ctx context.Context, config models.Config, provider models.Provider, params models.GenTextParams,
+	generate func(context.Context, models.GenTextParams) (*models.GenTextResponse, error)) (*models.GenTextResponse, error) {
+	ctx, span := telemetry.StartGeneration(ctx, config, provider, params)
+	start := time.Now()
+	res, err := generate(ctx, params)
+	telemetry.EndGeneration(span, config, res, err)
+	recordGeneration(config, models.GenerationRecord{
+		Provider: provider,
+		Model:    params.Model,
+		Status:   generationStatus(err),
+		Duration: time.Since(start),
+		Usage:    usageOf(res, err),
+	})
+	return res, err
+}
+
+// instrumentStream は、ストリームの反復を開始した時点でテキスト生成のスパンを作成し、最終イベントまたはエラーで終了します。
+// メトリクスには、最初のテキストを受け取るまでの時間も記録します。
+// 途中で反復を中断した場合は、レスポンスを記録せずにスパンを終了し、メトリクスには中断（canceled）として記録します。
+func instrumentStream(ctx context.Context, config models.Config, provider models.Provider, params models.GenTextParams,
+	stream func(context.Context, models.GenTextParams) iter.Seq2[models.StreamEvent, error]) iter.Seq2[models.StreamEvent, error] {
+	return func(yield func(models.StreamEvent, error) bool) {
+		ctx, span := telemetry.StartGeneration(ctx, config, provider, params)
+		start := time.Now()
+		var res *models.GenTextResponse
+		var streamErr error
+		var timeToFirstToken time.Duration
+		status := statusCanceled
+		defer func() {
+			telemetry.EndGeneration(span, config, res, streamErr)
+			recordGeneration(config, models.GenerationRecord{
+				Provider:         provider,
+				Model:            params.Model,
+				Stream:           true,
+				Status:           status,
+				Duration:         time.Since(start),
+				TimeToFirstToken: timeToFirstToken,
+				Usage:            usageOf(res, streamErr),
+			})
+		}()
+
+		for event, err := range stream(ctx, params) {
+			switch {
+			case err != nil:
+				streamErr = err
+				status = generationStatus(err)
+			case event.Response != nil:
+				res = event.Response
+				status = statusOK
+			}
+			if event.Delta != "" && timeToFirstToken == 0 {
+				timeToFirstToken = time.Since(start)
+			}
+			if !yield(event, err) {
+				return
+			}
+		}
+		if streamErr == nil {
+			status = statusOK
+		}
+	}
+}
+
+// recordGeneration は、Config.Metrics が設定されている場合に計測結果を記録します。
+func recordGeneration(config models.Config, record models.GenerationRecord) {
+	if config.Metrics != nil {
+		config.Metrics.RecordGeneration(record)
+	}
+}
+
+// generationStatus は、エラーに対応するメトリクスの status の値を返します。
+func generationStatus(err error) string {
+	if err == nil {
+		return statusOK
+	}
+	return telemetry.ErrorType(err)
+}
+
+// usageOf は、成功したレスポンスのトークン使用量を返します。
+func usageOf(res *models.GenTextResponse, err error) models.Usage {
+	if err != nil || res == nil {
+		return models.Usage{}
+	}
+	return res.Usage
+}
@@ -0,0 +1,131 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"sync"
+	"testing"
+
+	"github.com/obutora/ai-wrapper/models"
+)
+
+// recordingMetrics は、記録された計測結果を保持する MetricsRecorder です。
+type recordingMetrics struct {
+	mu      sync.Mutex
+	records []models.GenerationRecord
+}
+
+func (m *recordingMetrics) RecordGeneration(record models.GenerationRecord) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, record)
+}
+
+func fakeStream(events []models.StreamEvent, err error) func(context.Context, models.GenTextParams) iter.Seq2[models.StreamEvent, error] {
+	return func(ctx context.Context, params models.GenTextParams) iter.Seq2[models.StreamEvent, error] {
+		return func(yield func(models.StreamEvent, error) bool) {
+			for _, event := range events {
+				if !yield(event, nil) {
+					return
+				}
+			}
+			if err != nil {
+				yield(models.StreamEvent{}, err)
+			}
+		}
+	}
+}
+
+func TestInstrumentRecordsMetrics(t *testing.T) {
+	res := &models.GenTextResponse{Text: "Paris", Usage: models.Usage{InputTokens: 12, OutputTokens: 3, TotalTokens: 15}}
+	events := []models.StreamEvent{{Delta: "Par"}, {Delta: "is"}, {Done: true, Usage: res.Usage, Response: res}}
+	rateLimited := fmt.Errorf("%w: slow down", models.ErrRateLimited)
+
+	tests := []struct {
+		name       string
+		run        func(context.Context, models.Config, models.GenTextParams)
+		wantStatus string
+		wantStream bool
+		wantUsage  models.Usage
+		wantTTFT   bool
+	}{
+		{
+			name: "generate",
+			run: func(ctx context.Context, config models.Config, params models.GenTextParams) {
+				_, _ = instrumentGenerate(ctx, config, models.ProviderOpenAI, params, func(context.Context, models.GenTextParams) (*models.GenTextResponse, error) {
+					return res, nil
+				})
+			},
+			wantStatus: "ok",
+			wantUsage:  res.Usage,
+		},
+		{
+			name: "generate error",
+			run: func(ctx context.Context, config models.Config, params models.GenTextParams) {
+				_, _ = instrumentGenerate(ctx, config, models.ProviderOpenAI, params, func(context.Context, models.GenTextParams) (*models.GenTextResponse, error) {
+					return nil, rateLimited
+				})
+			},
+			wantStatus: "rate_limited",
+		},
+		{
+			name: "stream",
+			run: func(ctx context.Context, config models.Config, params models.GenTextParams) {
+				for range instrumentStream(ctx, config, models.ProviderOpenAI, params, fakeStream(events, nil)) {
+				}
+			},
+			wantStatus: "ok",
+			wantStream: true,
+			wantUsage:  res.Usage,
+			wantTTFT:   true,
+		},
+		{
+			name: "stream error",
+			run: func(ctx context.Context, config models.Config, params models.GenTextParams) {
+				for range instrumentStream(ctx, config, models.ProviderOpenAI, params, fakeStream(nil, rateLimited)) {
+				}
+			},
+			wantStatus: "rate_limited",
+			wantStream: true,
+		},
+		{
+			name: "stream abandoned",
+			run: func(ctx context.Context, config models.Config, params models.GenTextParams) {
+				for range instrumentStream(ctx, config, models.ProviderOpenAI, params, fakeStream(events, nil)) {
+					break
+				}
+			},
+			wantStatus: "canceled",
+			wantStream: true,
+			wantTTFT:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := &recordingMetrics{}
+			tt.run(context.Background(), models.Config{Metrics: metrics}, models.GenTextParams{Model: models.ModelGPT4o, Prompt: "capital of France?"})
+
+			if len(metrics.records) != 1 {
+				t.Fatalf("got %d records, want 1", len(metrics.records))
+			}
+			record := metrics.records[0]
+			if record.Provider != models.ProviderOpenAI || record.Model != models.ModelGPT4o {
+				t.Errorf("labels = %s/%s, want openai/gpt-4o", record.Provider, record.Model)
+			}
+			if record.Status != tt.wantStatus {
+				t.Errorf("Status = %q, want %q", record.Status, tt.wantStatus)
+			}
+			if record.Stream != tt.wantStream {
+				t.Errorf("Stream = %t, want %t", record.Stream, tt.wantStream)
+			}
+			if record.Usage != tt.wantUsage {
+				t.Errorf("Usage = %+v, want %+v", record.Usage, tt.wantUsage)
+			}
+			if got := record.TimeToFirstToken > 0; got != tt.wantTTFT {
+				t.Errorf("TimeToFirstToken recorded = %t, want %t", got, tt.wantTTFT)
+			}
+		})
+	}
+}
@@ -2,12 +2,18 @@ package providers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"iter"
+	"net/http"
+	"time"
 
 	"github.com/obutora/ai-wrapper/models"
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
 	"github.com/openai/openai-go/packages/param"
+	"github.com/openai/openai-go/shared"
 )
 
 // OpenAIClient は、OpenAIプロバイダのクライアントを表す構造体です。
@@ -18,35 +24,179 @@ type OpenAIClient struct {
 
 // NewOpenAIClient は、OpenAIクライアントの新しいインスタンスを作成します。
 func NewOpenAIClient(apiKey string, config models.Config) *OpenAIClient {
-	client := openai.NewClient(
-		option.WithAPIKey(apiKey),
-	)
+	// SDKによる再試行は各試行のフックやレート制限を通らないため常に無効にし、
+	// 再試行ポリシーが未指定の場合はSDKと同じ回数を再試行する既定のポリシーを使用します
+	opts := []option.RequestOption{option.WithAPIKey(apiKey), option.WithMaxRetries(0)}
+	if config.Retry.MaxAttempts == 0 {
+		config.Retry = models.DefaultRetryPolicy()
+	}
+	client := openai.NewClient(opts...)
 	return &OpenAIClient{client: client, config: config}
 }
 
 // GenText は、OpenAI APIを使用してテキストを生成します。
 func (c *OpenAIClient) GenText(params models.GenTextParams) (string, error, int) {
-	if params.Model == "" {
-		return "", models.ErrInvalidModel, 0
+	return c.GenTextContext(context.Background(), params)
+}
+
+// GenTextContext は、コンテキストを指定してOpenAI APIでテキストを生成します。
+// コンテキストのキャンセルやデッドラインはSDKの呼び出しに伝播されます。
+func (c *OpenAIClient) GenTextContext(ctx context.Context, params models.GenTextParams) (string, error, int) {
+	return legacyResult(c.Generate(ctx, params))
+}
+
+// Generate は、OpenAI APIを使用してテキストを生成し、詳細なレスポンスを返します。
+func (c *OpenAIClient) Generate(ctx context.Context, params models.GenTextParams) (*models.GenTextResponse, error) {
+	return instrumentGenerate(ctx, c.config, models.ProviderOpenAI, params, c.generate)
+}
+
+// generate は、OpenAI APIを呼び出してテキストを生成します。
+func (c *OpenAIClient) generate(ctx context.Context, params models.GenTextParams) (*models.GenTextResponse, error) {
+	if err := validateParams(params); err != nil {
+		return nil, err
+	}
+	if err := checkContextWindow(ctx, c, params, c.config); err != nil {
+		return nil, err
+	}
+
+	chatParams, err := c.buildParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	// APIリクエストを実行
+	var httpRes *http.Response
+	var completion *openai.ChatCompletion
+	start := time.Now()
+	attempts, err := withRetry(ctx, c.config.Retry, func() error {
+		var err error
+		completion, err = c.client.Chat.Completions.New(ctx, chatParams, option.WithResponseInto(&httpRes))
+		return err
+	})
+	if err != nil {
+		return nil, wrapAPIError(ctx, err)
 	}
+	latency := time.Since(start)
 
-	if len(params.Messages) == 0 && params.Prompt == "" {
-		return "", models.ErrEmptyMessages, 0
+	// レスポンスからテキストとトークン数を取得
+	if len(completion.Choices) == 0 {
+		return nil, wrapAPIError(ctx, errors.New("no completion choices returned"))
+	}
+	if choice := completion.Choices[0]; choice.FinishReason == "content_filter" && choice.Message.Content == "" && len(choice.Message.ToolCalls) == 0 {
+		return nil, contentFilteredError("response blocked: content_filter")
+	}
+
+	res := openAIResponse(completion)
+	res.Latency = latency
+	res.RequestID = requestID(httpRes, "x-request-id")
+	res.Attempts = attempts
+	res.Cost = cost(c.config, res.Usage, res.Model, params.Model)
+
+	return res, nil
+}
+
+// GenTextStream は、OpenAI APIのストリーミングを使用してテキストを逐次生成します。
+// 最終イベントには、終了理由とトークン使用量が含まれます。
+func (c *OpenAIClient) GenTextStream(ctx context.Context, params models.GenTextParams) iter.Seq2[models.StreamEvent, error] {
+	return instrumentStream(ctx, c.config, models.ProviderOpenAI, params, c.genTextStream)
+}
+
+// genTextStream は、OpenAI APIのストリーミングを呼び出してテキストを逐次生成します。
+func (c *OpenAIClient) genTextStream(ctx context.Context, params models.GenTextParams) iter.Seq2[models.StreamEvent, error] {
+	if err := validateStreamParams(params); err != nil {
+		return errStream(err)
+	}
+	if err := checkContextWindow(ctx, c, params, c.config); err != nil {
+		return errStream(err)
+	}
+
+	chatParams, err := c.buildParams(params)
+	if err != nil {
+		return errStream(err)
+	}
+	// 最終チャンクでトークン使用量を受け取る
+	chatParams.StreamOptions = openai.ChatCompletionStreamOptionsParam{
+		IncludeUsage: openai.Bool(true),
+	}
+
+	return retryStream(ctx, c.config.Retry, func(yield func(models.StreamEvent, error) bool) {
+		var httpRes *http.Response
+		start := time.Now()
+		stream := c.client.Chat.Completions.NewStreaming(ctx, chatParams, option.WithResponseInto(&httpRes))
+		defer stream.Close()
+
+		// チャンクを蓄積して、ツール呼び出しや終了理由を含む最終的なレスポンスを組み立てます
+		acc := openai.ChatCompletionAccumulator{}
+		var usage openai.CompletionUsage
+		for stream.Next() {
+			chunk := stream.Current()
+			acc.AddChunk(chunk)
+			if chunk.Usage.TotalTokens > 0 {
+				usage = chunk.Usage
+			}
+			if len(chunk.Choices) == 0 {
+				continue
+			}
+
+			delta := chunk.Choices[0].Delta.Content
+			if delta != "" {
+				if !yield(models.StreamEvent{Delta: delta}, nil) {
+					return
+				}
+			}
+		}
+		if err := stream.Err(); err != nil {
+			yield(models.StreamEvent{}, wrapAPIError(ctx, err))
+			return
+		}
+
+		acc.Usage = usage
+		res := openAIResponse(&acc.ChatCompletion)
+		res.Latency = time.Since(start)
+		res.Cost = cost(c.config, res.Usage, res.Model, params.Model)
+		res.RequestID = requestID(httpRes, "x-request-id")
+
+		yield(models.StreamEvent{
+			Done:         true,
+			FinishReason: res.FinishReason,
+			Usage:        res.Usage,
+			Response:     res,
+		}, nil)
+	})
+}
+
+// buildParams は、共通パラメータをOpenAI APIのリクエストパラメータに変換します。
+func (c *OpenAIClient) buildParams(params models.GenTextParams) (openai.ChatCompletionNewParams, error) {
+	// top_k とコンテキストキャッシュの参照はOpenAIでサポートされていません。推論モデルはさらに一部のパラメータをサポートしていません
+	unsupported := []string{paramTopK, paramCachedContent}
+	if params.Model.IsReasoningModel() {
+		unsupported = append(unsupported, paramTemperature, paramTopP, paramPresencePenalty, paramFrequencyPenalty)
+	}
+	if err := checkUnsupportedParams(params, unsupported...); err != nil {
+		return openai.ChatCompletionNewParams{}, err
 	}
 
-	ctx := context.Background()
 	messages := []openai.ChatCompletionMessageParamUnion{}
+	if params.SystemPrompt != "" {
+		messages = append(messages, openai.SystemMessage(params.SystemPrompt))
+	}
 
 	// メッセージがある場合は、それらを変換して使用します
 	if len(params.Messages) > 0 {
 		for _, msg := range params.Messages {
 			switch msg.Role {
 			case models.RoleUser:
-				messages = append(messages, openai.UserMessage(msg.Content))
+				message, err := openAIUserMessage(msg)
+				if err != nil {
+					return openai.ChatCompletionNewParams{}, err
+				}
+				messages = append(messages, message)
 			case models.RoleAssistant:
-				messages = append(messages, openai.AssistantMessage(msg.Content))
+				messages = append(messages, openAIAssistantMessage(msg))
 			case models.RoleSystem:
-				messages = append(messages, openai.SystemMessage(msg.Content))
+				messages = append(messages, openai.SystemMessage(msg.Text()))
+			case models.RoleTool:
+				messages = append(messages, openai.ToolMessage(msg.Content, msg.ToolCallID))
 			default:
 				messages = append(messages, openai.UserMessage(msg.Content))
 			}
@@ -64,26 +214,193 @@ func (c *OpenAIClient) GenText(params models.GenTextParams) (string, error, int)
 		Messages: messages,
 		Model:    model,
 		MaxCompletionTokens: param.Opt[int64]{
-			Value: int64(c.config.MaxToken),
+			Value: maxTokens(params, c.config),
 		},
 		MaxTokens: param.Opt[int64]{
-			Value: int64(c.config.MaxToken),
+			Value: maxTokens(params, c.config),
 		},
 	}
 
-	// APIリクエストを実行
-	completion, err := c.client.Chat.Completions.New(ctx, chatParams)
-	if err != nil {
-		return "", fmt.Errorf("%w: %v", models.ErrAPIRequest, err), 0
+	// サンプリングパラメータを設定
+	if params.Temperature != nil {
+		chatParams.Temperature = openai.Float(*params.Temperature)
+	}
+	if params.TopP != nil {
+		chatParams.TopP = openai.Float(*params.TopP)
+	}
+	if len(params.StopSequences) > 0 {
+		chatParams.Stop = openai.ChatCompletionNewParamsStopUnion{OfChatCompletionNewsStopArray: params.StopSequences}
+	}
+	if params.Seed != nil {
+		chatParams.Seed = openai.Int(*params.Seed)
+	}
+	if params.PresencePenalty != nil {
+		chatParams.PresencePenalty = openai.Float(*params.PresencePenalty)
+	}
+	if params.FrequencyPenalty != nil {
+		chatParams.FrequencyPenalty = openai.Float(*params.FrequencyPenalty)
 	}
 
-	// レスポンスからテキストとトークン数を取得
+	// ツールを設定
+	for _, tool := range params.Tools {
+		function := shared.FunctionDefinitionParam{
+			Name:       tool.Name,
+			Parameters: shared.FunctionParameters(tool.Parameters),
+		}
+		if tool.Description != "" {
+			function.Description = openai.String(tool.Description)
+		}
+		chatParams.Tools = append(chatParams.Tools, openai.ChatCompletionToolParam{Function: function})
+	}
+	switch params.ToolChoice {
+	case "":
+	case models.ToolChoiceAuto, models.ToolChoiceNone, models.ToolChoiceRequired:
+		chatParams.ToolChoice = openai.ChatCompletionToolChoiceOptionUnionParam{
+			OfAuto: openai.String(string(params.ToolChoice)),
+		}
+	default:
+		chatParams.ToolChoice = openai.ChatCompletionToolChoiceOptionUnionParam{
+			OfChatCompletionNamedToolChoice: &openai.ChatCompletionNamedToolChoiceParam{
+				Function: openai.ChatCompletionNamedToolChoiceFunctionParam{
+					Name: string(params.ToolChoice),
+				},
+			},
+		}
+	}
+
+	// 構造化出力を設定
+	if format := params.ResponseFormat; format != nil {
+		jsonSchema := shared.ResponseFormatJSONSchemaJSONSchemaParam{
+			Name:   responseFormatName(format),
+			Schema: format.Schema,
+			Strict: openai.Bool(format.Strict),
+		}
+		if format.Description != "" {
+			jsonSchema.Description = openai.String(format.Description)
+		}
+		chatParams.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONSchema: &shared.ResponseFormatJSONSchemaParam{JSONSchema: jsonSchema},
+		}
+	}
+
+	return chatParams, nil
+}
+
+// openAIUserMessage は、ユーザーメッセージをOpenAI APIのメッセージに変換します。
+// 画像やドキュメントを含む場合は、コンテンツパートの配列として設定します。
+func openAIUserMessage(msg models.Message) (openai.ChatCompletionMessageParamUnion, error) {
+	if len(msg.Parts) == 0 {
+		return openai.UserMessage(msg.Content), nil
+	}
+
+	parts := []openai.ChatCompletionContentPartUnionParam{}
+	for _, part := range msg.ContentParts() {
+		switch part.Type {
+		case models.PartTypeText:
+			parts = append(parts, openai.TextContentPart(part.Text))
+		case models.PartTypeImage:
+			imageURL := part.URL
+			if imageURL == "" {
+				imageURL = part.DataURL()
+			}
+			parts = append(parts, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{
+				URL: imageURL,
+			}))
+		case models.PartTypeDocument:
+			// Chat Completions APIは、URLによるファイル指定をサポートしていません
+			if part.URL != "" {
+				return openai.ChatCompletionMessageParamUnion{}, fmt.Errorf("%w: OpenAI does not support document URLs", models.ErrUnsupportedContent)
+			}
+			parts = append(parts, openai.FileContentPart(openai.ChatCompletionContentPartFileFileParam{
+				FileData: openai.String(part.DataURL()),
+				Filename: openai.String("document.pdf"),
+			}))
+		default:
+			return openai.ChatCompletionMessageParamUnion{}, fmt.Errorf("%w: part type %q", models.ErrUnsupportedContent, part.Type)
+		}
+	}
+
+	return openai.UserMessage(parts), nil
+}
+
+// openAIAssistantMessage は、アシスタントメッセージをOpenAI APIのメッセージに変換します。
+// ツール呼び出しを含む場合は、tool_calls として設定します。
+func openAIAssistantMessage(msg models.Message) openai.ChatCompletionMessageParamUnion {
+	if len(msg.ToolCalls) == 0 {
+		return openai.AssistantMessage(msg.Content)
+	}
+
+	assistant := openai.ChatCompletionAssistantMessageParam{}
+	if msg.Content != "" {
+		assistant.Content.OfString = openai.String(msg.Content)
+	}
+	for _, call := range msg.ToolCalls {
+		assistant.ToolCalls = append(assistant.ToolCalls, openai.ChatCompletionMessageToolCallParam{
+			ID: call.ID,
+			Function: openai.ChatCompletionMessageToolCallFunctionParam{
+				Name:      call.Name,
+				Arguments: string(call.Arguments),
+			},
+		})
+	}
+
+	return openai.ChatCompletionMessageParamUnion{OfAssistant: &assistant}
+}
+
+// openAIResponse は、OpenAI APIのレスポンスを共通のレスポンスに変換します。
+func openAIResponse(completion *openai.ChatCompletion) *models.GenTextResponse {
+	usage := openAIUsage(completion.Usage)
+	res := &models.GenTextResponse{
+		Tokens:   usage.TotalTokens,
+		Usage:    usage,
+		Provider: models.ProviderOpenAI,
+		Model:    models.Model(completion.Model),
+	}
 	if len(completion.Choices) == 0 {
-		return "", fmt.Errorf("no completion choices returned"), 0
+		return res
 	}
 
-	text := completion.Choices[0].Message.Content
-	tokens := int(completion.Usage.TotalTokens)
+	choice := completion.Choices[0]
+	res.Text = choice.Message.Content
+	res.FinishReason = openAIFinishReason(choice.FinishReason)
+	for _, call := range choice.Message.ToolCalls {
+		arguments := call.Function.Arguments
+		if arguments == "" {
+			arguments = "{}"
+		}
+		res.ToolCalls = append(res.ToolCalls, models.ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: json.RawMessage(arguments),
+		})
+	}
 
-	return text, nil, tokens
+	return res
+}
+
+// openAIUsage は、OpenAI APIのトークン使用量を共通の形式に変換します。
+func openAIUsage(usage openai.CompletionUsage) models.Usage {
+	return models.Usage{
+		InputTokens:       int(usage.PromptTokens),
+		OutputTokens:      int(usage.CompletionTokens),
+		CachedInputTokens: int(usage.PromptTokensDetails.CachedTokens),
+		ReasoningTokens:   int(usage.CompletionTokensDetails.ReasoningTokens),
+		TotalTokens:       int(usage.TotalTokens),
+	}
+}
+
+// openAIFinishReason は、OpenAI APIの終了理由を共通の値に正規化します。
+func openAIFinishReason(reason string) models.FinishReason {
+	switch reason {
+	case "stop":
+		return models.FinishReasonStop
+	case "length":
+		return models.FinishReasonLength
+	case "content_filter":
+		return models.FinishReasonSafety
+	case "tool_calls", "function_call":
+		return models.FinishReasonToolUse
+	default:
+		return models.FinishReasonOther
+	}
 }
@@ -0,0 +1,101 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/obutora/ai-wrapper/models"
+	"github.com/openai/openai-go"
+)
+
+const (
+	// openAIEmbeddingBatchSize は、OpenAIの埋め込みAPIに1回のリクエストで送信できる入力の最大数です。
+	openAIEmbeddingBatchSize = 2048
+	// openAIEmbeddingBatchTokens は、OpenAIの埋め込みAPIに1回のリクエストで送信できる入力の合計トークン数の上限です。
+	openAIEmbeddingBatchTokens = 300000
+)
+
+// Embed は、OpenAI APIを使用して埋め込みベクトルを生成します。
+// 入力の数または合計トークン数が上限を超える場合は、複数のリクエストに分割して送信します。
+func (c *OpenAIClient) Embed(ctx context.Context, params models.EmbedParams) (*models.EmbedResponse, error) {
+	if err := validateEmbedParams(params); err != nil {
+		return nil, err
+	}
+	batches, err := openAIEmbeddingBatches(params.Model, params.Inputs, openAIEmbeddingBatchSize, openAIEmbeddingBatchTokens)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &models.EmbedResponse{
+		Embeddings: make([][]float32, 0, len(params.Inputs)),
+		Provider:   models.ProviderOpenAI,
+		Model:      params.Model,
+	}
+	for _, batch := range batches {
+		embeddingParams := openai.EmbeddingNewParams{
+			Input: openai.EmbeddingNewParamsInputUnion{OfArrayOfStrings: batch},
+			Model: params.Model.APIID(),
+		}
+		if params.Dimensions > 0 {
+			embeddingParams.Dimensions = openai.Int(int64(params.Dimensions))
+		}
+
+		var embedding *openai.CreateEmbeddingResponse
+		_, err := withRetry(ctx, c.config.Retry, func() error {
+			var err error
+			embedding, err = c.client.Embeddings.New(ctx, embeddingParams)
+			return err
+		})
+		if err != nil {
+			return nil, wrapAPIError(ctx, err)
+		}
+
+		// レスポンスは入力と異なる順序で返される可能性があるため、index で並べ替えます
+		vectors := make([][]float32, len(batch))
+		for _, data := range embedding.Data {
+			if data.Index < 0 || int(data.Index) >= len(vectors) {
+				continue
+			}
+			vectors[data.Index] = toFloat32(data.Embedding)
+		}
+		res.Embeddings = append(res.Embeddings, vectors...)
+		res.Usage.InputTokens += int(embedding.Usage.PromptTokens)
+		res.Usage.TotalTokens += int(embedding.Usage.TotalTokens)
+		if embedding.Model != "" {
+			res.Model = models.Model(embedding.Model)
+		}
+	}
+
+	res.Cost = cost(c.config, res.Usage, res.Model, params.Model)
+	return res, nil
+}
+
+// openAIEmbeddingBatches は、入力をローカルのトークナイザで数え、各バッチが入力の数とトークン数の上限に収まるよう分割します。
+// 1つで上限を超える入力は、単独のバッチになります。
+func openAIEmbeddingBatches(model models.Model, inputs []string, maxInputs, maxTokens int) ([][]string, error) {
+	enc, err := openAIEncoding(model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tokenizer: %w", err)
+	}
+
+	var batches [][]string
+	start, tokens := 0, 0
+	for i, input := range inputs {
+		count := len(enc.EncodeOrdinary(input))
+		if i > start && (i-start == maxInputs || tokens+count > maxTokens) {
+			batches = append(batches, inputs[start:i])
+			start, tokens = i, 0
+		}
+		tokens += count
+	}
+	return append(batches, inputs[start:]), nil
+}
+
+// toFloat32 は、float64のベクトルをfloat32のベクトルに変換します。
+func toFloat32(values []float64) []float32 {
+	vector := make([]float32, len(values))
+	for i, v := range values {
+		vector[i] = float32(v)
+	}
+	return vector
+}
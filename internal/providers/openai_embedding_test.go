@@ -0,0 +1,58 @@
+package providers
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/obutora/ai-wrapper/models"
+)
+
+func TestOpenAIEmbeddingBatches(t *testing.T) {
+	// "hello world" は cl100k_base で2トークンです
+	repeat := func(text string, n int) []string {
+		inputs := make([]string, n)
+		for i := range inputs {
+			inputs[i] = text
+		}
+		return inputs
+	}
+	tests := []struct {
+		name      string
+		inputs    []string
+		maxInputs int
+		maxTokens int
+		want      []int
+	}{
+		{name: "single batch", inputs: repeat("hello world", 3), maxInputs: 10, maxTokens: 100, want: []int{3}},
+		{name: "input limit", inputs: repeat("hello world", 5), maxInputs: 2, maxTokens: 100, want: []int{2, 2, 1}},
+		{name: "token limit", inputs: repeat("hello world", 5), maxInputs: 10, maxTokens: 5, want: []int{2, 2, 1}},
+		{name: "exact token limit", inputs: repeat("hello world", 4), maxInputs: 10, maxTokens: 4, want: []int{2, 2}},
+		{
+			name:      "oversized input",
+			inputs:    []string{"hello world", strings.Repeat("hello world ", 10), "hello world"},
+			maxInputs: 10,
+			maxTokens: 5,
+			want:      []int{1, 1, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batches, err := openAIEmbeddingBatches(models.ModelTextEmbedding3Small, tt.inputs, tt.maxInputs, tt.maxTokens)
+			if err != nil {
+				t.Fatalf("openAIEmbeddingBatches() error = %v", err)
+			}
+			var sizes []int
+			for _, batch := range batches {
+				sizes = append(sizes, len(batch))
+			}
+			if !slices.Equal(sizes, tt.want) {
+				t.Errorf("batch sizes = %v, want %v", sizes, tt.want)
+			}
+			if got := slices.Concat(batches...); !slices.Equal(got, tt.inputs) {
+				t.Errorf("batches do not preserve the inputs: %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/obutora/ai-wrapper/models"
+)
+
+func TestGenerateEmptyResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		filtered bool
+	}{
+		{
+			name: "no choices",
+			body: `{"id": "chatcmpl-1", "object": "chat.completion", "created": 1700000000, "model": "gpt-4o-2024-08-06", "choices": []}`,
+		},
+		{
+			name: "content filter",
+			body: `{"id": "chatcmpl-1", "object": "chat.completion", "created": 1700000000, "model": "gpt-4o-2024-08-06",
+				"choices": [{"index": 0, "message": {"role": "assistant", "content": ""}, "finish_reason": "content_filter"}]}`,
+			filtered: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := newTracedOpenAIClient(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(tt.body))
+			}, models.Config{})
+
+			_, err := client.Generate(context.Background(), models.GenTextParams{
+				Model:  models.ModelGPT4o,
+				Prompt: "What is the capital of France?",
+			})
+			if !errors.Is(err, models.ErrAPIRequest) {
+				t.Errorf("Generate() error = %v, want ErrAPIRequest", err)
+			}
+			if got := errors.Is(err, models.ErrContentFiltered); got != tt.filtered {
+				t.Errorf("errors.Is(err, ErrContentFiltered) = %v, want %v", got, tt.filtered)
+			}
+		})
+	}
+}
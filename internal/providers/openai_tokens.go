@@ -0,0 +1,109 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/obutora/ai-wrapper/models"
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+const (
+	// openAITokensPerMessage は、メッセージごとの区切りに使用されるトークン数です。
+	openAITokensPerMessage = 3
+	// openAIReplyTokens は、応答の開始を表すトークン数です。
+	openAIReplyTokens = 3
+	// openAIImageTokens は、画像1枚あたりの入力トークン数の概算です（高解像度の1024x1024の画像に相当します）。
+	openAIImageTokens = 765
+)
+
+func init() {
+	// トークナイザの定義は、実行時にダウンロードせず、埋め込まれたファイルから読み込みます
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+}
+
+// tiktokenEncodings は、エンコーディング名ごとのトークナイザです。最初に使用した時点で一度だけ読み込み、プロセス内で共有します。
+var tiktokenEncodings = map[string]func() (*tiktoken.Tiktoken, error){
+	tiktoken.MODEL_O200K_BASE:  loadEncoding(tiktoken.MODEL_O200K_BASE),
+	tiktoken.MODEL_CL100K_BASE: loadEncoding(tiktoken.MODEL_CL100K_BASE),
+}
+
+// loadEncoding は、エンコーディングを一度だけ読み込む関数を返します。
+func loadEncoding(name string) func() (*tiktoken.Tiktoken, error) {
+	return sync.OnceValues(func() (*tiktoken.Tiktoken, error) {
+		return tiktoken.GetEncoding(name)
+	})
+}
+
+// openAIEncoding は、モデルが使用するトークナイザを返します。
+func openAIEncoding(model models.Model) (*tiktoken.Tiktoken, error) {
+	name := tiktoken.MODEL_O200K_BASE
+	modelName := string(model)
+	if model == models.ModelGPT4 || strings.HasPrefix(modelName, "gpt-4-") ||
+		strings.HasPrefix(modelName, "gpt-3.5") || strings.HasPrefix(modelName, "text-embedding-") {
+		name = tiktoken.MODEL_CL100K_BASE
+	}
+	return tiktokenEncodings[name]()
+}
+
+// CountTokens は、ローカルのトークナイザを使用して入力トークン数を計算します。
+// メッセージの区切りはOpenAIのチャット形式に従って加算します。画像は1枚あたり一定のトークン数として概算し、ドキュメントは含めません。
+func (c *OpenAIClient) CountTokens(ctx context.Context, params models.GenTextParams) (int, error) {
+	if err := validateParams(params); err != nil {
+		return 0, err
+	}
+
+	enc, err := openAIEncoding(params.Model)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load tokenizer for %s: %w", params.Model, err)
+	}
+	count := func(text string) int {
+		return len(enc.EncodeOrdinary(text))
+	}
+
+	total := openAIReplyTokens
+	if params.SystemPrompt != "" {
+		total += openAITokensPerMessage + count(string(models.RoleSystem)) + count(params.SystemPrompt)
+	}
+
+	messages := params.Messages
+	if len(messages) == 0 {
+		messages = []models.Message{{Role: models.RoleUser, Content: params.Prompt}}
+	}
+	for _, msg := range messages {
+		total += openAITokensPerMessage + count(string(msg.Role))
+		for _, part := range msg.ContentParts() {
+			switch part.Type {
+			case models.PartTypeText:
+				total += count(part.Text)
+			case models.PartTypeImage:
+				total += openAIImageTokens
+			}
+		}
+		for _, call := range msg.ToolCalls {
+			total += count(call.Name) + count(string(call.Arguments))
+		}
+	}
+
+	// ツール定義と構造化出力のスキーマは、JSONとして概算します
+	for _, tool := range params.Tools {
+		definition, err := json.Marshal(tool)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal tool %s: %w", tool.Name, err)
+		}
+		total += count(string(definition))
+	}
+	if params.ResponseFormat != nil {
+		schema, err := json.Marshal(params.ResponseFormat.Schema)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal response schema: %w", err)
+		}
+		total += count(string(schema))
+	}
+
+	return total, nil
+}
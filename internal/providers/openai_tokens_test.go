@@ -0,0 +1,33 @@
+package providers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/obutora/ai-wrapper/models"
+)
+
+func TestOpenAICountTokens(t *testing.T) {
+	client := &OpenAIClient{}
+	tests := []struct {
+		name  string
+		model models.Model
+		want  int
+	}{
+		// "hello world" は、どちらのエンコーディングでも2トークンです
+		{name: "o200k_base", model: models.ModelGPT4o, want: openAIReplyTokens + openAITokensPerMessage + 1 + 2},
+		{name: "cl100k_base", model: models.ModelGPT4, want: openAIReplyTokens + openAITokensPerMessage + 1 + 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := client.CountTokens(context.Background(), models.GenTextParams{Model: tt.model, Prompt: "hello world"})
+			if err != nil {
+				t.Fatalf("CountTokens() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("CountTokens() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/obutora/ai-wrapper/internal/telemetry"
+	"github.com/obutora/ai-wrapper/models"
+	"github.com/openai/openai-go"
+	"google.golang.org/genai"
+)
+
+// 再試行ポリシーの既定値
+const (
+	defaultBaseBackoff = 500 * time.Millisecond
+	defaultMaxBackoff  = 30 * time.Second
+)
+
+// attemptHookKey は、試行ごとに呼び出す関数をコンテキストに格納するキーです。
+type attemptHookKey struct{}
+
+// WithAttemptHook は、HTTPリクエストの各試行（再試行を含む）の前に hook を呼び出すコンテキストを返します。
+// hook がエラーを返した場合は、リクエストを送信せずにそのエラーを返します。UnifiedClient のレート制限に使用します。
+func WithAttemptHook(ctx context.Context, hook func(context.Context) error) context.Context {
+	return context.WithValue(ctx, attemptHookKey{}, hook)
+}
+
+// withoutAttemptHook は、試行ごとの関数を呼び出さないコンテキストを返します。
+// テキスト生成の前に行うトークン数の計算など、生成のリクエストではない呼び出しに使用します。
+func withoutAttemptHook(ctx context.Context) context.Context {
+	return context.WithValue(ctx, attemptHookKey{}, (func(context.Context) error)(nil))
+}
+
+// beforeAttempt は、コンテキストに試行ごとの関数が設定されている場合に呼び出します。
+func beforeAttempt(ctx context.Context) error {
+	if hook, _ := ctx.Value(attemptHookKey{}).(func(context.Context) error); hook != nil {
+		return hook(ctx)
+	}
+	return nil
+}
+
+// withRetry は、再試行ポリシーに従って fn を実行し、試行回数を返します。
+// 再試行可能なエラーの場合のみ、待機してから再試行します。
+func withRetry(ctx context.Context, policy models.RetryPolicy, fn func() error) (int, error) {
+	for attempt := 1; ; attempt++ {
+		if err := beforeAttempt(ctx); err != nil {
+			return attempt, err
+		}
+		span := telemetry.StartAttempt(ctx, policy, attempt)
+		err := fn()
+		if err != nil {
+			// 試行のスパンには、呼び出し元に返す場合と同じ分類でエラーを記録します
+			telemetry.RecordError(span, wrapAPIError(ctx, err))
+		}
+		span.End()
+		if err == nil || !waitRetry(ctx, policy, attempt, err) {
+			return attempt, err
+		}
+	}
+}
+
+// waitRetry は、attempt 回目の試行が err で失敗した後に再試行すべきかを判定し、必要な時間だけ待機します。
+// 再試行しない場合（再試行不可能なエラー、試行回数の上限、コンテキストのデッドラインを超える場合）は false を返します。
+func waitRetry(ctx context.Context, policy models.RetryPolicy, attempt int, err error) bool {
+	if attempt >= policy.MaxAttempts || ctx.Err() != nil {
+		return false
+	}
+
+	retryable, retryAfter := retryInfo(err)
+	if !retryable {
+		return false
+	}
+	if status, _ := errorStatus(err); policy.SkipRateLimited && status == http.StatusTooManyRequests {
+		return false
+	}
+
+	delay := retryAfter
+	if delay <= 0 {
+		delay = backoff(policy, attempt)
+	}
+
+	// 待機するとデッドラインを超える場合は、再試行しません
+	if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+		return false
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// retryStream は、最初のイベントを返す前に再試行可能なエラーで失敗したストリームを、再試行ポリシーに従って開き直します。
+// 一度でもイベントを返した後のエラーは、重複した出力を避けるためそのまま返します。
+// 最終イベントのレスポンスには、試行回数が記録されます。
+func retryStream(ctx context.Context, policy models.RetryPolicy, stream iter.Seq2[models.StreamEvent, error]) iter.Seq2[models.StreamEvent, error] {
+	return func(yield func(models.StreamEvent, error) bool) {
+		for attempt := 1; ; attempt++ {
+			if err := beforeAttempt(ctx); err != nil {
+				yield(models.StreamEvent{}, err)
+				return
+			}
+			span := telemetry.StartAttempt(ctx, policy, attempt)
+			started := false
+			var streamErr error
+			for event, err := range stream {
+				if err != nil {
+					streamErr = err
+					break
+				}
+				started = true
+				if event.Response != nil {
+					event.Response.Attempts = attempt
+				}
+				if !yield(event, nil) {
+					span.End()
+					return
+				}
+			}
+			telemetry.EndSpan(span, streamErr)
+			if streamErr == nil {
+				return
+			}
+			if started || !waitRetry(ctx, policy, attempt, streamErr) {
+				yield(models.StreamEvent{}, streamErr)
+				return
+			}
+		}
+	}
+}
+
+// backoff は、attempt 回目の失敗後の待機時間を指数バックオフで計算します。
+func backoff(policy models.RetryPolicy, attempt int) time.Duration {
+	base := policy.BaseBackoff
+	if base <= 0 {
+		base = defaultBaseBackoff
+	}
+	maxBackoff := policy.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+
+	delay := maxBackoff
+	if shift := attempt - 1; shift < 32 && base<<shift > 0 && base<<shift < maxBackoff {
+		delay = base << shift
+	}
+
+	if jitter := min(max(policy.Jitter, 0), 1); jitter > 0 {
+		delay -= time.Duration(rand.Float64() * jitter * float64(delay))
+	}
+	return delay
+}
+
+// retryInfo は、エラーが再試行可能かどうかと、プロバイダから指定された待機時間を返します。
+func retryInfo(err error) (bool, time.Duration) {
+	status, header := errorStatus(err)
+	if status == 0 {
+		// HTTPレスポンスを受け取る前のネットワークエラーは、再試行可能とみなします
+		var netErr net.Error
+		return errors.As(err, &netErr), 0
+	}
+
+	// OpenAIはクォータ（請求上限）の超過も 429 で返しますが、待機しても解消しないため再試行しません
+	var openAIErr *openai.Error
+	if errors.As(err, &openAIErr) && openAIErr.Code == "insufficient_quota" {
+		return false, 0
+	}
+
+	switch {
+	case status == http.StatusRequestTimeout, status == http.StatusConflict,
+		status == http.StatusTooManyRequests, status >= http.StatusInternalServerError:
+		return true, retryAfter(status, header)
+	default:
+		return false, 0
+	}
+}
+
+// errorStatus は、各SDKのエラーからHTTPステータスコードとレスポンスヘッダーを取得します。
+func errorStatus(err error) (int, http.Header) {
+	var openAIErr *openai.Error
+	if errors.As(err, &openAIErr) {
+		return openAIErr.StatusCode, responseHeader(openAIErr.Response)
+	}
+
+	var anthropicErr *anthropic.Error
+	if errors.As(err, &anthropicErr) {
+		return anthropicErr.StatusCode, responseHeader(anthropicErr.Response)
+	}
+
+	var geminiErr genai.APIError
+	if errors.As(err, &geminiErr) {
+		return geminiErr.Code, nil
+	}
+
+	return 0, nil
+}
+
+// responseHeader は、HTTPレスポンスのヘッダーを返します。
+func responseHeader(res *http.Response) http.Header {
+	if res == nil {
+		return nil
+	}
+	return res.Header
+}
+
+// retryAfter は、レスポンスヘッダーからプロバイダが指定した待機時間を取得します。
+// retry-after-ms、retry-after（秒数またはHTTP日付）、anthropic-ratelimit-*-reset の順に参照します。
+func retryAfter(status int, header http.Header) time.Duration {
+	if header == nil {
+		return 0
+	}
+
+	if ms, err := strconv.ParseFloat(header.Get("retry-after-ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+
+	if value := header.Get("retry-after"); value != "" {
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+			return time.Duration(seconds * float64(time.Second))
+		}
+		if date, err := http.ParseTime(value); err == nil {
+			return time.Until(date)
+		}
+	}
+
+	// Anthropicのレート制限は、制限がリセットされる時刻（RFC 3339）を返します
+	// このヘッダーは 429 以外のレスポンスにも付くため、429 の場合に、残りが0になった制限のリセット時刻のみを参照します
+	// 複数の制限に達している場合は、最も遅いリセット時刻まで待機します
+	if status != http.StatusTooManyRequests {
+		return 0
+	}
+	var delay time.Duration
+	for key, values := range header {
+		key = strings.ToLower(key)
+		if !strings.HasPrefix(key, "anthropic-ratelimit-") || !strings.HasSuffix(key, "-reset") || len(values) == 0 {
+			continue
+		}
+		if header.Get(strings.TrimSuffix(key, "-reset")+"-remaining") != "0" {
+			continue
+		}
+		if reset, err := time.Parse(time.RFC3339, values[0]); err == nil {
+			delay = max(delay, time.Until(reset))
+		}
+	}
+	return delay
+}
@@ -0,0 +1,223 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/obutora/ai-wrapper/models"
+	"github.com/openai/openai-go"
+	"google.golang.org/genai"
+)
+
+func TestBackoff(t *testing.T) {
+	policy := models.RetryPolicy{BaseBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	tests := []struct {
+		name    string
+		policy  models.RetryPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{name: "first attempt", policy: policy, attempt: 1, want: 100 * time.Millisecond},
+		{name: "doubles", policy: policy, attempt: 3, want: 400 * time.Millisecond},
+		{name: "capped", policy: policy, attempt: 5, want: time.Second},
+		{name: "overflow", policy: policy, attempt: 100, want: time.Second},
+		{name: "defaults", policy: models.RetryPolicy{}, attempt: 1, want: defaultBaseBackoff},
+		{name: "default cap", policy: models.RetryPolicy{}, attempt: 20, want: defaultMaxBackoff},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := backoff(tt.policy, tt.attempt); got != tt.want {
+				t.Errorf("backoff(attempt %d) = %v, want %v", tt.attempt, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("jitter", func(t *testing.T) {
+		policy := models.RetryPolicy{BaseBackoff: time.Second, MaxBackoff: time.Minute, Jitter: 0.5}
+		for range 100 {
+			if got := backoff(policy, 1); got < 500*time.Millisecond || got > time.Second {
+				t.Fatalf("backoff() = %v, want between 500ms and 1s", got)
+			}
+		}
+	})
+}
+
+func TestRetryAfter(t *testing.T) {
+	in := func(d time.Duration) string { return time.Now().Add(d).UTC().Format(time.RFC3339) }
+	tests := []struct {
+		name   string
+		status int
+		header http.Header
+		// 時刻から計算する待機時間は、RFC 3339 の秒未満の切り捨てを考慮して (want-1s, want] の範囲で比較します
+		want   time.Duration
+		approx bool
+	}{
+		{name: "no header", status: http.StatusTooManyRequests, want: 0},
+		{
+			name:   "retry-after-ms",
+			status: http.StatusTooManyRequests,
+			header: http.Header{"Retry-After-Ms": {"1500"}, "Retry-After": {"9"}},
+			want:   1500 * time.Millisecond,
+		},
+		{
+			name:   "retry-after seconds",
+			status: http.StatusServiceUnavailable,
+			header: http.Header{"Retry-After": {"2"}},
+			want:   2 * time.Second,
+		},
+		{
+			name:   "anthropic exhausted limit",
+			status: http.StatusTooManyRequests,
+			header: http.Header{
+				"Anthropic-Ratelimit-Requests-Remaining": {"0"},
+				"Anthropic-Ratelimit-Requests-Reset":     {in(10 * time.Second)},
+				"Anthropic-Ratelimit-Tokens-Remaining":   {"120000"},
+				"Anthropic-Ratelimit-Tokens-Reset":       {in(50 * time.Second)},
+			},
+			want:   10 * time.Second,
+			approx: true,
+		},
+		{
+			name:   "anthropic several exhausted limits",
+			status: http.StatusTooManyRequests,
+			header: http.Header{
+				"Anthropic-Ratelimit-Input-Tokens-Remaining":  {"0"},
+				"Anthropic-Ratelimit-Input-Tokens-Reset":      {in(5 * time.Second)},
+				"Anthropic-Ratelimit-Output-Tokens-Remaining": {"0"},
+				"Anthropic-Ratelimit-Output-Tokens-Reset":     {in(20 * time.Second)},
+			},
+			want:   20 * time.Second,
+			approx: true,
+		},
+		{
+			name:   "anthropic limits not exhausted",
+			status: http.StatusTooManyRequests,
+			header: http.Header{
+				"Anthropic-Ratelimit-Requests-Remaining": {"3"},
+				"Anthropic-Ratelimit-Requests-Reset":     {in(10 * time.Second)},
+			},
+			want: 0,
+		},
+		{
+			name:   "anthropic reset on overloaded",
+			status: 529,
+			header: http.Header{
+				"Anthropic-Ratelimit-Requests-Remaining": {"0"},
+				"Anthropic-Ratelimit-Requests-Reset":     {in(10 * time.Second)},
+			},
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := retryAfter(tt.status, tt.header)
+			if tt.approx {
+				if got <= tt.want-time.Second || got > tt.want {
+					t.Errorf("retryAfter() = %v, want about %v", got, tt.want)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("retryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryInfo(t *testing.T) {
+	openAIError := func(status int, code string, header http.Header) error {
+		return &openai.Error{StatusCode: status, Code: code, Response: &http.Response{StatusCode: status, Header: header}}
+	}
+	tests := []struct {
+		name       string
+		err        error
+		retryable  bool
+		retryAfter time.Duration
+	}{
+		{
+			name:       "openai rate limit",
+			err:        openAIError(http.StatusTooManyRequests, "rate_limit_exceeded", http.Header{"Retry-After": {"1"}}),
+			retryable:  true,
+			retryAfter: time.Second,
+		},
+		{
+			name: "openai insufficient quota",
+			err:  openAIError(http.StatusTooManyRequests, "insufficient_quota", http.Header{"Retry-After": {"1"}}),
+		},
+		{name: "openai bad request", err: openAIError(http.StatusBadRequest, "", nil)},
+		{name: "openai server error", err: openAIError(http.StatusInternalServerError, "", nil), retryable: true},
+		{name: "anthropic overloaded", err: &anthropic.Error{StatusCode: 529}, retryable: true},
+		{name: "anthropic conflict", err: &anthropic.Error{StatusCode: http.StatusConflict}, retryable: true},
+		{name: "gemini unavailable", err: genai.APIError{Code: http.StatusServiceUnavailable}, retryable: true},
+		{name: "gemini not found", err: genai.APIError{Code: http.StatusNotFound}},
+		{name: "network error", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, retryable: true},
+		{name: "other error", err: errors.New("boom")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retryable, retryAfter := retryInfo(tt.err)
+			if retryable != tt.retryable || retryAfter != tt.retryAfter {
+				t.Errorf("retryInfo() = (%v, %v), want (%v, %v)", retryable, retryAfter, tt.retryable, tt.retryAfter)
+			}
+		})
+	}
+}
+
+func TestWithRetrySkipRateLimited(t *testing.T) {
+	policy := models.RetryPolicy{MaxAttempts: 3, BaseBackoff: time.Millisecond, SkipRateLimited: true}
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "rate limit", err: genai.APIError{Code: http.StatusTooManyRequests}, want: 1},
+		{name: "unavailable", err: genai.APIError{Code: http.StatusServiceUnavailable}, want: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts, _ := withRetry(context.Background(), policy, func() error { return tt.err })
+			if attempts != tt.want {
+				t.Errorf("withRetry() attempts = %d, want %d", attempts, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithRetryAttemptHook(t *testing.T) {
+	policy := models.RetryPolicy{MaxAttempts: 3, BaseBackoff: time.Millisecond}
+	unavailable := genai.APIError{Code: http.StatusServiceUnavailable}
+
+	var hooks, calls int
+	ctx := WithAttemptHook(context.Background(), func(context.Context) error {
+		hooks++
+		if hooks == 3 {
+			return models.ErrRequestCanceled
+		}
+		return nil
+	})
+	attempts, err := withRetry(ctx, policy, func() error {
+		calls++
+		return unavailable
+	})
+	if !errors.Is(err, models.ErrRequestCanceled) {
+		t.Errorf("withRetry() error = %v, want the hook's error", err)
+	}
+	if hooks != 3 || calls != 2 || attempts != 3 {
+		t.Errorf("hooks = %d, calls = %d, attempts = %d, want 3, 2, 3", hooks, calls, attempts)
+	}
+
+	// トークン数の計算など、生成以外の呼び出しでは呼び出しません
+	hooks = 0
+	if _, err := withRetry(withoutAttemptHook(ctx), policy, func() error { return nil }); err != nil || hooks != 0 {
+		t.Errorf("withRetry() without hook: error = %v, hooks = %d, want nil and 0", err, hooks)
+	}
+}
@@ -0,0 +1,263 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/obutora/ai-wrapper/internal/telemetry"
+	"github.com/obutora/ai-wrapper/models"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const chatCompletionJSON = `{
+	"id": "chatcmpl-123",
+	"object": "chat.completion",
+	"created": 1700000000,
+	"model": "gpt-4o-2024-08-06",
+	"choices": [{
+		"index": 0,
+		"message": {"role": "assistant", "content": "Paris"},
+		"finish_reason": "stop"
+	}],
+	"usage": {"prompt_tokens": 12, "completion_tokens": 3, "total_tokens": 15}
+}`
+
+// newTracedOpenAIClient は、テスト用のサーバーに接続し、インメモリのエクスポーターにスパンを記録するクライアントを作成します。
+func newTracedOpenAIClient(t *testing.T, handler http.HandlerFunc, config models.Config) (*OpenAIClient, *tracetest.InMemoryExporter) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+	config.Tracing.TracerProvider = provider
+
+	client := openai.NewClient(
+		option.WithAPIKey("test-key"),
+		option.WithBaseURL(server.URL),
+		option.WithMaxRetries(0),
+	)
+	return &OpenAIClient{client: client, config: config}, exporter
+}
+
+func writeCompletion(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(chatCompletionJSON))
+}
+
+func attributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value, len(span.Attributes))
+	for _, kv := range span.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func findSpan(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	t.Fatalf("span %q not found", name)
+	return tracetest.SpanStub{}
+}
+
+func TestGenerateSpanAttributes(t *testing.T) {
+	client, exporter := newTracedOpenAIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeCompletion(w)
+	}, models.Config{MaxToken: 256})
+
+	temperature := 0.2
+	_, err := client.Generate(context.Background(), models.GenTextParams{
+		Model:       models.ModelGPT4o,
+		Prompt:      "What is the capital of France?",
+		Temperature: &temperature,
+	})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "chat gpt-4o" {
+		t.Errorf("span name = %q, want %q", span.Name, "chat gpt-4o")
+	}
+
+	attrs := attributes(span)
+	want := map[attribute.Key]attribute.Value{
+		telemetry.AttrOperationName:      attribute.StringValue("chat"),
+		telemetry.AttrSystem:             attribute.StringValue("openai"),
+		telemetry.AttrRequestModel:       attribute.StringValue("gpt-4o"),
+		telemetry.AttrRequestMaxTokens:   attribute.IntValue(256),
+		telemetry.AttrRequestTemperature: attribute.Float64Value(0.2),
+		telemetry.AttrResponseModel:      attribute.StringValue("gpt-4o-2024-08-06"),
+		telemetry.AttrUsageInputTokens:   attribute.IntValue(12),
+		telemetry.AttrUsageOutputTokens:  attribute.IntValue(3),
+	}
+	for key, value := range want {
+		if got, ok := attrs[key]; !ok || got != value {
+			t.Errorf("attribute %s = %v, want %v", key, got.Emit(), value.Emit())
+		}
+	}
+	if got := attrs[telemetry.AttrResponseFinishReasons].AsStringSlice(); !slices.Equal(got, []string{"stop"}) {
+		t.Errorf("attribute %s = %v, want [stop]", telemetry.AttrResponseFinishReasons, got)
+	}
+	if len(span.Events) != 0 {
+		t.Errorf("got %d events without CaptureContent, want 0", len(span.Events))
+	}
+}
+
+func TestGenerateSpanContentEvents(t *testing.T) {
+	client, exporter := newTracedOpenAIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeCompletion(w)
+	}, models.Config{Tracing: models.TracingConfig{CaptureContent: true}})
+
+	_, err := client.Generate(context.Background(), models.GenTextParams{
+		Model:        models.ModelGPT4o,
+		SystemPrompt: "Answer briefly.",
+		Prompt:       "What is the capital of France?",
+	})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	span := exporter.GetSpans()[0]
+	var names []string
+	for _, event := range span.Events {
+		names = append(names, event.Name)
+	}
+	wantNames := []string{"gen_ai.system.message", "gen_ai.user.message", "gen_ai.choice"}
+	if !slices.Equal(names, wantNames) {
+		t.Fatalf("events = %v, want %v", names, wantNames)
+	}
+
+	choice := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Events[2].Attributes {
+		choice[kv.Key] = kv.Value
+	}
+	if got := choice["message"].AsString(); got != "Paris" {
+		t.Errorf("choice message = %q, want %q", got, "Paris")
+	}
+	if got := choice["finish_reason"].AsString(); got != "stop" {
+		t.Errorf("choice finish_reason = %q, want %q", got, "stop")
+	}
+}
+
+func TestGenerateSpanRetries(t *testing.T) {
+	var calls atomic.Int32
+	client, exporter := newTracedOpenAIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"error": {"message": "overloaded", "type": "server_error"}}`))
+			return
+		}
+		writeCompletion(w)
+	}, models.Config{Retry: models.RetryPolicy{MaxAttempts: 2, BaseBackoff: time.Millisecond}})
+
+	if _, err := client.Generate(context.Background(), models.GenTextParams{
+		Model:  models.ModelGPT4o,
+		Prompt: "What is the capital of France?",
+	}); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	chat := findSpan(t, spans, "chat gpt-4o")
+	first := findSpan(t, spans, "attempt 1")
+	second := findSpan(t, spans, "attempt 2")
+
+	for _, attempt := range []tracetest.SpanStub{first, second} {
+		if attempt.Parent.SpanID() != chat.SpanContext.SpanID() {
+			t.Errorf("%s is not a child of the chat span", attempt.Name)
+		}
+	}
+	if first.Status.Code != codes.Error {
+		t.Errorf("attempt 1 status = %v, want Error", first.Status.Code)
+	}
+	if got := attributes(first)[telemetry.AttrErrorType].AsString(); got != "provider_unavailable" {
+		t.Errorf("attempt 1 %s = %q, want %q", telemetry.AttrErrorType, got, "provider_unavailable")
+	}
+	if second.Status.Code == codes.Error {
+		t.Errorf("attempt 2 status = Error, want Unset")
+	}
+	if chat.Status.Code == codes.Error {
+		t.Errorf("chat span status = Error, want Unset")
+	}
+}
+
+func TestGenerateSpanError(t *testing.T) {
+	client, exporter := newTracedOpenAIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error": {"message": "slow down", "type": "rate_limit_error"}}`))
+	}, models.Config{})
+
+	if _, err := client.Generate(context.Background(), models.GenTextParams{
+		Model:  models.ModelGPT4o,
+		Prompt: "What is the capital of France?",
+	}); err == nil {
+		t.Fatal("Generate() error = nil, want rate limit error")
+	}
+
+	span := exporter.GetSpans()[0]
+	if span.Status.Code != codes.Error {
+		t.Errorf("status = %v, want Error", span.Status.Code)
+	}
+	if got := attributes(span)[telemetry.AttrErrorType].AsString(); got != "rate_limited" {
+		t.Errorf("%s = %q, want %q", telemetry.AttrErrorType, got, "rate_limited")
+	}
+}
+
+func TestGenTextStreamSpan(t *testing.T) {
+	client, exporter := newTracedOpenAIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(`data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4o-2024-08-06","choices":[{"index":0,"delta":{"role":"assistant","content":"Paris"},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4o-2024-08-06","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4o-2024-08-06","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}
+
+data: [DONE]
+
+`))
+	}, models.Config{})
+
+	for _, err := range client.GenTextStream(context.Background(), models.GenTextParams{
+		Model:  models.ModelGPT4o,
+		Prompt: "What is the capital of France?",
+	}) {
+		if err != nil {
+			t.Fatalf("GenTextStream() error = %v", err)
+		}
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	attrs := attributes(spans[0])
+	if got := attrs[telemetry.AttrUsageOutputTokens].AsInt64(); got != 3 {
+		t.Errorf("%s = %d, want 3", telemetry.AttrUsageOutputTokens, got)
+	}
+	if got := attrs[telemetry.AttrResponseModel].AsString(); got != "gpt-4o-2024-08-06" {
+		t.Errorf("%s = %q, want %q", telemetry.AttrResponseModel, got, "gpt-4o-2024-08-06")
+	}
+}
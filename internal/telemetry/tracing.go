@@ -0,0 +1,237 @@
+// Package telemetry は、OpenTelemetry の GenAI セマンティック規約に従ったスパンの作成を提供します。
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/obutora/ai-wrapper/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// InstrumentationName は、Tracer の名前です。
+const InstrumentationName = "github.com/obutora/ai-wrapper"
+
+// OperationChat は、テキスト生成の操作名です。
+const OperationChat = "chat"
+
+// GenAI セマンティック規約の属性
+const (
+	AttrOperationName          = attribute.Key("gen_ai.operation.name")
+	AttrSystem                 = attribute.Key("gen_ai.system")
+	AttrRequestModel           = attribute.Key("gen_ai.request.model")
+	AttrRequestMaxTokens       = attribute.Key("gen_ai.request.max_tokens")
+	AttrRequestTemperature     = attribute.Key("gen_ai.request.temperature")
+	AttrRequestTopP            = attribute.Key("gen_ai.request.top_p")
+	AttrRequestTopK            = attribute.Key("gen_ai.request.top_k")
+	AttrRequestStopSequences   = attribute.Key("gen_ai.request.stop_sequences")
+	AttrRequestSeed            = attribute.Key("gen_ai.request.seed")
+	AttrRequestPresencePenalty = attribute.Key("gen_ai.request.presence_penalty")
+	AttrRequestFreqPenalty     = attribute.Key("gen_ai.request.frequency_penalty")
+	AttrResponseModel          = attribute.Key("gen_ai.response.model")
+	AttrResponseFinishReasons  = attribute.Key("gen_ai.response.finish_reasons")
+	AttrUsageInputTokens       = attribute.Key("gen_ai.usage.input_tokens")
+	AttrUsageOutputTokens      = attribute.Key("gen_ai.usage.output_tokens")
+	AttrErrorType              = attribute.Key("error.type")
+)
+
+// このライブラリ独自の属性
+const (
+	AttrAttempt        = attribute.Key("ai_wrapper.attempt")
+	AttrFallbackModels = attribute.Key("ai_wrapper.fallback.models")
+	AttrFallbackCount  = attribute.Key("ai_wrapper.fallback.failures")
+)
+
+// コンテンツのイベント（TracingConfig.CaptureContent が有効な場合のみ記録）
+const (
+	eventChoice  = "gen_ai.choice"
+	attrContent  = attribute.Key("content")
+	attrToolCall = attribute.Key("tool_calls")
+	attrToolID   = attribute.Key("id")
+	attrIndex    = attribute.Key("index")
+	attrFinish   = attribute.Key("finish_reason")
+	attrMessage  = attribute.Key("message")
+)
+
+// System は、プロバイダに対応する gen_ai.system の値を返します。
+func System(provider models.Provider) string {
+	if provider == models.ProviderGemini {
+		return "gcp.gemini"
+	}
+	return string(provider)
+}
+
+// Tracer は、設定に従って Tracer を返します。
+func Tracer(config models.TracingConfig) trace.Tracer {
+	provider := config.TracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(InstrumentationName)
+}
+
+// StartGeneration は、テキスト生成のスパンを開始します。スパン名は「chat {モデル名}」です。
+func StartGeneration(ctx context.Context, config models.Config, provider models.Provider, params models.GenTextParams) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{
+		AttrOperationName.String(OperationChat),
+		AttrSystem.String(System(provider)),
+		AttrRequestModel.String(string(params.Model)),
+	}
+	if maxTokens := params.MaxTokens; maxTokens > 0 {
+		attrs = append(attrs, AttrRequestMaxTokens.Int(maxTokens))
+	} else if config.MaxToken > 0 {
+		attrs = append(attrs, AttrRequestMaxTokens.Int(config.MaxToken))
+	}
+	if params.Temperature != nil {
+		attrs = append(attrs, AttrRequestTemperature.Float64(*params.Temperature))
+	}
+	if params.TopP != nil {
+		attrs = append(attrs, AttrRequestTopP.Float64(*params.TopP))
+	}
+	if params.TopK != nil {
+		attrs = append(attrs, AttrRequestTopK.Int(*params.TopK))
+	}
+	if len(params.StopSequences) > 0 {
+		attrs = append(attrs, AttrRequestStopSequences.StringSlice(params.StopSequences))
+	}
+	if params.Seed != nil {
+		attrs = append(attrs, AttrRequestSeed.Int64(*params.Seed))
+	}
+	if params.PresencePenalty != nil {
+		attrs = append(attrs, AttrRequestPresencePenalty.Float64(*params.PresencePenalty))
+	}
+	if params.FrequencyPenalty != nil {
+		attrs = append(attrs, AttrRequestFreqPenalty.Float64(*params.FrequencyPenalty))
+	}
+
+	ctx, span := Tracer(config.Tracing).Start(ctx, OperationChat+" "+string(params.Model),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	if config.Tracing.CaptureContent && span.IsRecording() {
+		addPromptEvents(span, params)
+	}
+	return ctx, span
+}
+
+// EndGeneration は、レスポンスまたはエラーを記録してテキスト生成のスパンを終了します。
+func EndGeneration(span trace.Span, config models.Config, res *models.GenTextResponse, err error) {
+	defer span.End()
+	if err != nil {
+		RecordError(span, err)
+		return
+	}
+	if res == nil {
+		return
+	}
+
+	span.SetAttributes(
+		AttrResponseModel.String(string(res.Model)),
+		AttrUsageInputTokens.Int(res.Usage.InputTokens),
+		AttrUsageOutputTokens.Int(res.Usage.OutputTokens),
+	)
+	if res.FinishReason != "" {
+		span.SetAttributes(AttrResponseFinishReasons.StringSlice([]string{string(res.FinishReason)}))
+	}
+	if config.Tracing.CaptureContent {
+		attrs := []attribute.KeyValue{
+			attrIndex.Int(0),
+			attrFinish.String(string(res.FinishReason)),
+			attrMessage.String(res.Text),
+		}
+		if len(res.ToolCalls) > 0 {
+			attrs = append(attrs, attrToolCall.String(toJSON(res.ToolCalls)))
+		}
+		span.AddEvent(eventChoice, trace.WithAttributes(attrs...))
+	}
+}
+
+// StartAttempt は、再試行が有効で親のスパンが記録されている場合に、試行ごとの子スパンを開始します。
+// それ以外の場合は、何も記録しないスパンを返します。
+func StartAttempt(ctx context.Context, policy models.RetryPolicy, attempt int) trace.Span {
+	parent := trace.SpanFromContext(ctx)
+	if policy.MaxAttempts <= 1 || !parent.IsRecording() {
+		return noop.Span{}
+	}
+	_, span := parent.TracerProvider().Tracer(InstrumentationName).Start(ctx, fmt.Sprintf("attempt %d", attempt),
+		trace.WithAttributes(AttrAttempt.Int(attempt)),
+	)
+	return span
+}
+
+// EndSpan は、エラーを記録してスパンを終了します。
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		RecordError(span, err)
+	}
+	span.End()
+}
+
+// RecordError は、エラーとその分類をスパンに記録します。
+func RecordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	span.SetAttributes(AttrErrorType.String(ErrorType(err)))
+}
+
+// ErrorType は、エラーの分類を error.type の値として返します。
+func ErrorType(err error) string {
+	switch {
+	case errors.Is(err, models.ErrRequestCanceled):
+		return "canceled"
+	case errors.Is(err, models.ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, models.ErrAuthentication):
+		return "authentication"
+	case errors.Is(err, models.ErrContextLengthExceeded):
+		return "context_length_exceeded"
+	case errors.Is(err, models.ErrContentFiltered):
+		return "content_filtered"
+	case errors.Is(err, models.ErrProviderUnavailable):
+		return "provider_unavailable"
+	case errors.Is(err, models.ErrAPIRequest):
+		return "api_error"
+	default:
+		return "_OTHER"
+	}
+}
+
+// addPromptEvents は、システムプロンプトとメッセージをイベントとして記録します。
+func addPromptEvents(span trace.Span, params models.GenTextParams) {
+	if params.SystemPrompt != "" {
+		span.AddEvent(messageEvent(models.RoleSystem), trace.WithAttributes(attrContent.String(params.SystemPrompt)))
+	}
+	if len(params.Messages) == 0 && params.Prompt != "" {
+		span.AddEvent(messageEvent(models.RoleUser), trace.WithAttributes(attrContent.String(params.Prompt)))
+	}
+	for _, msg := range params.Messages {
+		attrs := []attribute.KeyValue{attrContent.String(msg.Text())}
+		if len(msg.ToolCalls) > 0 {
+			attrs = append(attrs, attrToolCall.String(toJSON(msg.ToolCalls)))
+		}
+		if msg.ToolCallID != "" {
+			attrs = append(attrs, attrToolID.String(msg.ToolCallID))
+		}
+		span.AddEvent(messageEvent(msg.Role), trace.WithAttributes(attrs...))
+	}
+}
+
+// messageEvent は、メッセージの役割に対応するイベント名（例: gen_ai.user.message）を返します。
+func messageEvent(role models.Role) string {
+	return "gen_ai." + string(role) + ".message"
+}
+
+// toJSON は、値をJSON文字列に変換します。
+func toJSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,346 @@
+package wrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"sync"
+	"time"
+
+	"github.com/obutora/ai-wrapper/models"
+)
+
+const (
+	defaultRateLimitCooldown = 30 * time.Second
+	defaultAuthCooldown      = 10 * time.Minute
+)
+
+// keyPool は、同じプロバイダの複数のAPIキーを切り替えて使用するクライアントです。
+// レート制限や認証エラーを返したキーは一定時間除外し、残りのキーで同じリクエストを再送します。
+type keyPool struct {
+	provider          Provider
+	selection         KeySelection
+	rateLimitCooldown time.Duration
+	authCooldown      time.Duration
+
+	mu   sync.Mutex
+	keys []*pooledKey
+	next int
+}
+
+// pooledKey は、プール内のAPIキーとそのクライアントです。
+type pooledKey struct {
+	client LLMWrapper
+	stats  KeyStats
+}
+
+// newKeyPool は、APIキーごとにクライアントを作成し、プールにまとめます。
+// キーが複数ある場合は、レート制限を受けたキーで再試行せずにすぐに別のキーに切り替えるため、各キーのクライアントでは429を再試行しません。
+// SDK組み込みの再試行は429も再試行するため、Retry が未設定の場合は代わりに DefaultRetryPolicy を使用します。
+func newKeyPool(provider Provider, apiKeys []string, config models.Config) (*keyPool, error) {
+	if len(apiKeys) == 0 {
+		return nil, ErrInvalidAPIKey
+	}
+	if len(apiKeys) > 1 {
+		if config.Retry.MaxAttempts == 0 {
+			config.Retry = models.DefaultRetryPolicy()
+		}
+		config.Retry.SkipRateLimited = true
+	}
+
+	pool := &keyPool{
+		provider:          provider,
+		selection:         config.KeyPool.Selection,
+		rateLimitCooldown: config.KeyPool.RateLimitCooldown,
+		authCooldown:      config.KeyPool.AuthCooldown,
+	}
+	if pool.rateLimitCooldown <= 0 {
+		pool.rateLimitCooldown = defaultRateLimitCooldown
+	}
+	if pool.authCooldown <= 0 {
+		pool.authCooldown = defaultAuthCooldown
+	}
+
+	for _, apiKey := range apiKeys {
+		client, err := NewClient(provider, apiKey, config)
+		if err != nil {
+			return nil, err
+		}
+		pool.keys = append(pool.keys, &pooledKey{
+			client: client,
+			stats:  KeyStats{Key: maskKey(apiKey)},
+		})
+	}
+	return pool, nil
+}
+
+// maskKey は、APIキーを末尾4文字のみ表示する形にマスクします。
+func maskKey(apiKey string) string {
+	if len(apiKey) <= 8 {
+		return "****"
+	}
+	return "..." + apiKey[len(apiKey)-4:]
+}
+
+// stats は、各キーの使用状況のコピーを返します。
+func (p *keyPool) stats() []KeyStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := make([]KeyStats, len(p.keys))
+	for i, key := range p.keys {
+		stats[i] = key.stats
+	}
+	return stats
+}
+
+// acquire は、tried に含まれないキーから次に使用するキーを選択します。
+// すべてのキーが除外されている場合は、除外期限が最も早いキーを使用します。
+// 選択できるキーがない場合は ErrNoAvailableKey を返します。
+func (p *keyPool) acquire(tried map[int]bool) (int, LLMWrapper, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	selected := -1
+	fallback := -1
+	for offset := range len(p.keys) {
+		i := (p.next + offset) % len(p.keys)
+		if tried[i] {
+			continue
+		}
+		stats := &p.keys[i].stats
+		if stats.EjectedUntil.After(now) {
+			if fallback < 0 || stats.EjectedUntil.Before(p.keys[fallback].stats.EjectedUntil) {
+				fallback = i
+			}
+			continue
+		}
+		if selected < 0 {
+			selected = i
+			if p.selection != KeySelectionLeastRecentlyLimited {
+				break
+			}
+		} else if stats.LastLimited.Before(p.keys[selected].stats.LastLimited) {
+			selected = i
+		}
+	}
+	if selected < 0 {
+		selected = fallback
+	}
+	if selected < 0 {
+		return 0, nil, fmt.Errorf("%w: provider %s", ErrNoAvailableKey, p.provider)
+	}
+
+	p.next = (selected + 1) % len(p.keys)
+	key := p.keys[selected]
+	key.stats.Requests++
+	key.stats.LastUsed = now
+	return selected, key.client, nil
+}
+
+// release は、リクエストの結果をキーの使用状況に記録します。
+// レート制限や認証エラーの場合はキーを除外し、別のキーで再送すべきかを返します。
+func (p *keyPool) release(i int, usage Usage, err error) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := &p.keys[i].stats
+	if err == nil {
+		stats.Usage.Add(usage)
+		return false
+	}
+
+	stats.Failures++
+	now := time.Now()
+	switch {
+	case errors.Is(err, ErrRateLimited):
+		stats.RateLimited++
+		stats.LastLimited = now
+		cooldown := p.rateLimitCooldown
+		var providerErr *ProviderError
+		if errors.As(err, &providerErr) && providerErr.RetryAfter > 0 {
+			cooldown = providerErr.RetryAfter
+		}
+		stats.EjectedUntil = now.Add(cooldown)
+		return true
+	case errors.Is(err, ErrAuthentication):
+		stats.AuthFailures++
+		stats.EjectedUntil = now.Add(p.authCooldown)
+		return true
+	default:
+		return false
+	}
+}
+
+// excluded は、リクエストで使用しないキーを返します。
+// Gemini のコンテキストキャッシュはプロジェクトごとに作成されるため、CachedContent を指定した場合は最初のキーのみを使用します。
+func (p *keyPool) excluded(params GenTextParams) map[int]bool {
+	excluded := map[int]bool{}
+	if params.CachedContent != "" {
+		for i := 1; i < len(p.keys); i++ {
+			excluded[i] = true
+		}
+	}
+	return excluded
+}
+
+// GenText は、プール内のキーを使用してテキストを生成します。
+func (p *keyPool) GenText(params GenTextParams) (string, error, int) {
+	return p.GenTextContext(context.Background(), params)
+}
+
+// GenTextContext は、コンテキストを指定し、プール内のキーを使用してテキストを生成します。
+func (p *keyPool) GenTextContext(ctx context.Context, params GenTextParams) (string, error, int) {
+	res, err := p.Generate(ctx, params)
+	if err != nil {
+		return "", err, 0
+	}
+	return res.Text, nil, res.Usage.TotalTokens
+}
+
+// Generate は、プール内のキーを使用してテキストを生成します。
+// レート制限や認証エラーの場合は、別のキーで再送します。
+func (p *keyPool) Generate(ctx context.Context, params GenTextParams) (*GenTextResponse, error) {
+	tried := p.excluded(params)
+	for {
+		i, client, err := p.acquire(tried)
+		if err != nil {
+			return nil, err
+		}
+		tried[i] = true
+
+		res, err := client.Generate(ctx, params)
+		var usage Usage
+		if res != nil {
+			usage = res.Usage
+		}
+		if !p.release(i, usage, err) || len(tried) == len(p.keys) {
+			return res, err
+		}
+	}
+}
+
+// GenTextStream は、プール内のキーを使用し、生成されたテキストを逐次返します。
+// 別のキーでの再送は、最初のイベントを受け取る前に失敗した場合にのみ行います。
+func (p *keyPool) GenTextStream(ctx context.Context, params GenTextParams) iter.Seq2[StreamEvent, error] {
+	return func(yield func(StreamEvent, error) bool) {
+		tried := p.excluded(params)
+		for {
+			i, client, err := p.acquire(tried)
+			if err != nil {
+				yield(StreamEvent{}, err)
+				return
+			}
+			tried[i] = true
+
+			started := false
+			var usage Usage
+			for event, streamErr := range client.GenTextStream(ctx, params) {
+				if streamErr != nil {
+					err = streamErr
+					break
+				}
+				started = true
+				if event.Response != nil {
+					usage = event.Response.Usage
+				}
+				if !yield(event, nil) {
+					p.release(i, usage, nil)
+					return
+				}
+			}
+
+			if !p.release(i, usage, err) || started || len(tried) == len(p.keys) {
+				if err != nil {
+					yield(StreamEvent{}, err)
+				}
+				return
+			}
+		}
+	}
+}
+
+// Embed は、プール内のキーを使用して埋め込みベクトルを生成します。
+func (p *keyPool) Embed(ctx context.Context, params EmbedParams) (*EmbedResponse, error) {
+	if _, ok := p.keys[0].client.(Embedder); !ok {
+		return nil, fmt.Errorf("%w: provider %s", ErrEmbeddingNotSupported, p.provider)
+	}
+
+	tried := map[int]bool{}
+	for {
+		i, client, err := p.acquire(tried)
+		if err != nil {
+			return nil, err
+		}
+		tried[i] = true
+
+		res, err := client.(Embedder).Embed(ctx, params)
+		var usage Usage
+		if res != nil {
+			usage = res.Usage
+		}
+		if !p.release(i, usage, err) || len(tried) == len(p.keys) {
+			return res, err
+		}
+	}
+}
+
+// CountTokens は、プール内のキーを使用して入力トークン数を計算します。
+func (p *keyPool) CountTokens(ctx context.Context, params GenTextParams) (int, error) {
+	if _, ok := p.keys[0].client.(TokenCounter); !ok {
+		return 0, fmt.Errorf("%w: provider %s", ErrTokenCountingNotSupported, p.provider)
+	}
+
+	tried := p.excluded(params)
+	for {
+		i, client, err := p.acquire(tried)
+		if err != nil {
+			return 0, err
+		}
+		tried[i] = true
+
+		count, err := client.(TokenCounter).CountTokens(ctx, params)
+		if !p.release(i, Usage{}, err) || len(tried) == len(p.keys) {
+			return count, err
+		}
+	}
+}
+
+// cacheManager は、コンテキストキャッシュの管理に使用するクライアントを返します。
+// キャッシュはキー（プロジェクト）ごとに作成されるため、常に最初のキーを使用します。
+func (p *keyPool) cacheManager() (CacheManager, error) {
+	manager, ok := p.keys[0].client.(CacheManager)
+	if !ok {
+		return nil, fmt.Errorf("%w: provider %s", ErrCacheManagementNotSupported, p.provider)
+	}
+	return manager, nil
+}
+
+// CreateCache は、最初のキーを使用してコンテキストキャッシュを作成します。
+func (p *keyPool) CreateCache(ctx context.Context, params CachedContentParams) (*CachedContent, error) {
+	manager, err := p.cacheManager()
+	if err != nil {
+		return nil, err
+	}
+	return manager.CreateCache(ctx, params)
+}
+
+// ListCaches は、最初のキーで作成されたコンテキストキャッシュの一覧を返します。
+func (p *keyPool) ListCaches(ctx context.Context) ([]CachedContent, error) {
+	manager, err := p.cacheManager()
+	if err != nil {
+		return nil, err
+	}
+	return manager.ListCaches(ctx)
+}
+
+// DeleteCache は、最初のキーを使用してコンテキストキャッシュを削除します。
+func (p *keyPool) DeleteCache(ctx context.Context, name string) error {
+	manager, err := p.cacheManager()
+	if err != nil {
+		return err
+	}
+	return manager.DeleteCache(ctx, name)
+}
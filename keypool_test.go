@@ -0,0 +1,202 @@
+package wrapper
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"testing"
+	"time"
+)
+
+// fakeKeyClient は、キーごとのクライアントの代わりに、決まったエラーまたはレスポンスを返すテスト用のクライアントです。
+type fakeKeyClient struct {
+	name  string
+	err   error
+	calls int
+}
+
+func (c *fakeKeyClient) GenText(params GenTextParams) (string, error, int) {
+	return c.GenTextContext(context.Background(), params)
+}
+
+func (c *fakeKeyClient) GenTextContext(ctx context.Context, params GenTextParams) (string, error, int) {
+	res, err := c.Generate(ctx, params)
+	if err != nil {
+		return "", err, 0
+	}
+	return res.Text, nil, res.Usage.TotalTokens
+}
+
+func (c *fakeKeyClient) Generate(ctx context.Context, params GenTextParams) (*GenTextResponse, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &GenTextResponse{Text: c.name, Usage: Usage{TotalTokens: 10}}, nil
+}
+
+func (c *fakeKeyClient) GenTextStream(ctx context.Context, params GenTextParams) iter.Seq2[StreamEvent, error] {
+	return func(yield func(StreamEvent, error) bool) {
+		res, err := c.Generate(ctx, params)
+		if err != nil {
+			yield(StreamEvent{}, err)
+			return
+		}
+		if yield(StreamEvent{Delta: res.Text}, nil) {
+			yield(StreamEvent{Done: true, Response: res}, nil)
+		}
+	}
+}
+
+func newTestKeyPool(selection KeySelection, clients ...*fakeKeyClient) *keyPool {
+	pool := &keyPool{
+		provider:          ProviderOpenAI,
+		selection:         selection,
+		rateLimitCooldown: defaultRateLimitCooldown,
+		authCooldown:      defaultAuthCooldown,
+	}
+	for _, client := range clients {
+		pool.keys = append(pool.keys, &pooledKey{client: client, stats: KeyStats{Key: client.name}})
+	}
+	return pool
+}
+
+func rateLimitError(retryAfter time.Duration) error {
+	return &ProviderError{Provider: ProviderOpenAI, StatusCode: 429, Category: ErrRateLimited, RetryAfter: retryAfter}
+}
+
+func TestKeyPoolRotatesOnRateLimit(t *testing.T) {
+	limited := &fakeKeyClient{name: "a", err: rateLimitError(0)}
+	healthy := &fakeKeyClient{name: "b"}
+	pool := newTestKeyPool(KeySelectionRoundRobin, limited, healthy)
+
+	for range 3 {
+		res, err := pool.Generate(context.Background(), GenTextParams{Prompt: "hi"})
+		if err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+		if res.Text != "b" {
+			t.Errorf("Generate() text = %q, want %q", res.Text, "b")
+		}
+	}
+
+	// 除外されたキーは、除外期限までは使用されません
+	if limited.calls != 1 || healthy.calls != 3 {
+		t.Errorf("calls = (%d, %d), want (1, 3)", limited.calls, healthy.calls)
+	}
+	stats := pool.stats()
+	if stats[0].RateLimited != 1 || stats[0].Failures != 1 {
+		t.Errorf("stats[0] = %+v, want 1 rate limit and 1 failure", stats[0])
+	}
+	if until := time.Until(stats[0].EjectedUntil); until <= defaultRateLimitCooldown-time.Second || until > defaultRateLimitCooldown {
+		t.Errorf("stats[0] ejected for %v, want about %v", until, defaultRateLimitCooldown)
+	}
+	if stats[1].Requests != 3 || stats[1].Usage.TotalTokens != 30 {
+		t.Errorf("stats[1] = %+v, want 3 requests and 30 tokens", stats[1])
+	}
+}
+
+func TestKeyPoolEjection(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want time.Duration
+	}{
+		{name: "rate limit cooldown", err: rateLimitError(0), want: defaultRateLimitCooldown},
+		{name: "retry after", err: rateLimitError(5 * time.Second), want: 5 * time.Second},
+		{
+			name: "authentication",
+			err:  &ProviderError{Provider: ProviderOpenAI, StatusCode: 401, Category: ErrAuthentication},
+			want: defaultAuthCooldown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := newTestKeyPool(KeySelectionRoundRobin, &fakeKeyClient{name: "a", err: tt.err}, &fakeKeyClient{name: "b"})
+			if _, err := pool.Generate(context.Background(), GenTextParams{Prompt: "hi"}); err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			if until := time.Until(pool.stats()[0].EjectedUntil); until <= tt.want-time.Second || until > tt.want {
+				t.Errorf("ejected for %v, want about %v", until, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyPoolDoesNotRotateOnOtherErrors(t *testing.T) {
+	badRequest := &ProviderError{Provider: ProviderOpenAI, StatusCode: 400}
+	first := &fakeKeyClient{name: "a", err: badRequest}
+	second := &fakeKeyClient{name: "b"}
+	pool := newTestKeyPool(KeySelectionRoundRobin, first, second)
+
+	if _, err := pool.Generate(context.Background(), GenTextParams{Prompt: "hi"}); !errors.Is(err, badRequest) {
+		t.Fatalf("Generate() error = %v, want the bad request error", err)
+	}
+	if second.calls != 0 {
+		t.Errorf("second key called %d times, want 0", second.calls)
+	}
+	if !pool.stats()[0].EjectedUntil.IsZero() {
+		t.Error("key ejected after a non-rate-limit error")
+	}
+}
+
+func TestKeyPoolAllKeysLimited(t *testing.T) {
+	first := &fakeKeyClient{name: "a", err: rateLimitError(time.Minute)}
+	second := &fakeKeyClient{name: "b", err: rateLimitError(time.Second)}
+	pool := newTestKeyPool(KeySelectionRoundRobin, first, second)
+
+	if _, err := pool.Generate(context.Background(), GenTextParams{Prompt: "hi"}); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Generate() error = %v, want ErrRateLimited", err)
+	}
+
+	// すべてのキーが除外されている場合は、除外期限が最も早いキーから使用します
+	second.err = nil
+	res, err := pool.Generate(context.Background(), GenTextParams{Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if res.Text != "b" {
+		t.Errorf("Generate() text = %q, want %q", res.Text, "b")
+	}
+}
+
+func TestKeyPoolLeastRecentlyLimited(t *testing.T) {
+	pool := newTestKeyPool(KeySelectionLeastRecentlyLimited,
+		&fakeKeyClient{name: "a"}, &fakeKeyClient{name: "b"}, &fakeKeyClient{name: "c"})
+	now := time.Now()
+	pool.keys[0].stats.LastLimited = now.Add(-time.Minute)
+	pool.keys[1].stats.LastLimited = now.Add(-time.Hour)
+	pool.keys[2].stats.LastLimited = now.Add(-time.Second)
+
+	res, err := pool.Generate(context.Background(), GenTextParams{Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if res.Text != "b" {
+		t.Errorf("Generate() text = %q, want %q", res.Text, "b")
+	}
+}
+
+func TestKeyPoolStreamRotatesBeforeFirstEvent(t *testing.T) {
+	pool := newTestKeyPool(KeySelectionRoundRobin, &fakeKeyClient{name: "a", err: rateLimitError(0)}, &fakeKeyClient{name: "b"})
+
+	var text string
+	for event, err := range pool.GenTextStream(context.Background(), GenTextParams{Prompt: "hi"}) {
+		if err != nil {
+			t.Fatalf("GenTextStream() error = %v", err)
+		}
+		text += event.Delta
+	}
+	if text != "b" {
+		t.Errorf("streamed text = %q, want %q", text, "b")
+	}
+}
+
+func TestKeyPoolAcquireNoAvailableKey(t *testing.T) {
+	pool := newTestKeyPool(KeySelectionRoundRobin, &fakeKeyClient{name: "a"})
+
+	if _, _, err := pool.acquire(map[int]bool{0: true}); !errors.Is(err, ErrNoAvailableKey) {
+		t.Errorf("acquire() error = %v, want ErrNoAvailableKey", err)
+	}
+}
@@ -0,0 +1,122 @@
+package wrapper
+
+import (
+	"github.com/obutora/ai-wrapper/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultMetricsNamespace は、メトリクス名の既定の接頭辞です。
+const defaultMetricsNamespace = "ai_wrapper"
+
+// MetricsConfig は、Metrics の設定です。
+type MetricsConfig = models.MetricsConfig
+
+// GenerationRecord は、1回のテキスト生成の呼び出しの計測結果です。
+type GenerationRecord = models.GenerationRecord
+
+// MetricsRecorder は、テキスト生成の呼び出しの計測結果を記録するインターフェースです。
+type MetricsRecorder = models.MetricsRecorder
+
+// Metrics は、テキスト生成の呼び出しを Prometheus のメトリクスとして集計するコレクターです。
+// prometheus.Collector と MetricsRecorder を実装しているため、任意の Registry に登録し、Config.Metrics に設定して使用します。
+// 複数のゴルーチンから安全に使用できます。
+//
+// 次のメトリクスを、プロバイダ（provider）とモデル（model）のラベルごとに集計します。
+//   - {namespace}_requests_total: リクエスト数。status ラベルは GenerationRecord.Status です。
+//   - {namespace}_request_duration_seconds: リクエストの所要時間。stream ラベルはストリーミングかどうかです。
+//   - {namespace}_time_to_first_token_seconds: ストリーミングで最初のテキストを受け取るまでの時間。
+//   - {namespace}_tokens_total: 使用したトークン数。type ラベルは input、output、cached_input、cache_creation、reasoning のいずれかです。
+type Metrics struct {
+	requests         *prometheus.CounterVec
+	duration         *prometheus.HistogramVec
+	timeToFirstToken *prometheus.HistogramVec
+	tokens           *prometheus.CounterVec
+}
+
+// NewMetrics は、新しい Metrics を作成します。
+func NewMetrics(config MetricsConfig) *Metrics {
+	namespace := config.Namespace
+	if namespace == "" {
+		namespace = defaultMetricsNamespace
+	}
+	durationBuckets := config.DurationBuckets
+	if len(durationBuckets) == 0 {
+		durationBuckets = prometheus.ExponentialBuckets(0.1, 2, 11)
+	}
+	ttftBuckets := config.TimeToFirstTokenBuckets
+	if len(ttftBuckets) == 0 {
+		ttftBuckets = prometheus.ExponentialBuckets(0.05, 2, 10)
+	}
+
+	return &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "requests_total",
+			Help:        "Number of text generation requests by provider, model, streaming and status.",
+			ConstLabels: config.ConstLabels,
+		}, []string{"provider", "model", "stream", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "request_duration_seconds",
+			Help:        "Duration of text generation requests in seconds, including retries.",
+			ConstLabels: config.ConstLabels,
+			Buckets:     durationBuckets,
+		}, []string{"provider", "model", "stream"}),
+		timeToFirstToken: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "time_to_first_token_seconds",
+			Help:        "Time until the first text delta of a streaming request in seconds.",
+			ConstLabels: config.ConstLabels,
+			Buckets:     ttftBuckets,
+		}, []string{"provider", "model"}),
+		tokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "tokens_total",
+			Help:        "Number of tokens used by text generation requests.",
+			ConstLabels: config.ConstLabels,
+		}, []string{"provider", "model", "type"}),
+	}
+}
+
+// Describe は、prometheus.Collector の実装です。
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.requests.Describe(ch)
+	m.duration.Describe(ch)
+	m.timeToFirstToken.Describe(ch)
+	m.tokens.Describe(ch)
+}
+
+// Collect は、prometheus.Collector の実装です。
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.requests.Collect(ch)
+	m.duration.Collect(ch)
+	m.timeToFirstToken.Collect(ch)
+	m.tokens.Collect(ch)
+}
+
+// RecordGeneration は、テキスト生成の呼び出しの計測結果を記録します。MetricsRecorder の実装です。
+func (m *Metrics) RecordGeneration(record GenerationRecord) {
+	provider, model := string(record.Provider), string(record.Model)
+	stream := "false"
+	if record.Stream {
+		stream = "true"
+	}
+
+	m.requests.WithLabelValues(provider, model, stream, record.Status).Inc()
+	m.duration.WithLabelValues(provider, model, stream).Observe(record.Duration.Seconds())
+	if record.TimeToFirstToken > 0 {
+		m.timeToFirstToken.WithLabelValues(provider, model).Observe(record.TimeToFirstToken.Seconds())
+	}
+
+	for tokenType, count := range map[string]int{
+		"input":          record.Usage.InputTokens,
+		"output":         record.Usage.OutputTokens,
+		"cached_input":   record.Usage.CachedInputTokens,
+		"cache_creation": record.Usage.CacheCreationTokens,
+		"reasoning":      record.Usage.ReasoningTokens,
+	} {
+		if count > 0 {
+			m.tokens.WithLabelValues(provider, model, tokenType).Add(float64(count))
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package wrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+
+	"github.com/obutora/ai-wrapper/models"
+)
+
+// errNoResponse は、Handler が最終イベントを返さずに終了した場合のエラーです。
+var errNoResponse = errors.New("handler returned no response")
+
+// GenTextRequest は、Middleware が受け取るテキスト生成のリクエストです。
+type GenTextRequest = models.GenTextRequest
+
+// Handler は、テキスト生成のリクエストを処理し、イベントを逐次返す関数です。
+type Handler = models.Handler
+
+// Middleware は、Handler を包んで処理を追加する関数です。
+type Middleware = models.Middleware
+
+// ResponseEvents は、レスポンスをストリームのイベントとして返します。
+// Middleware でキャッシュや固定の応答を返す場合に使用します。テキスト全体を1つの差分として返した後、最終イベントを返します。
+func ResponseEvents(res *GenTextResponse) iter.Seq2[StreamEvent, error] {
+	return func(yield func(StreamEvent, error) bool) {
+		if res.Text != "" && !yield(StreamEvent{Delta: res.Text}, nil) {
+			return
+		}
+		yield(StreamEvent{
+			Done:         true,
+			FinishReason: res.FinishReason,
+			Usage:        res.Usage,
+			Response:     res,
+		}, nil)
+	}
+}
+
+// chainMiddleware は、terminal を Middleware で包んだ Handler を返します。先頭の Middleware が最も外側になります。
+func chainMiddleware(terminal Handler, middleware []Middleware) Handler {
+	handler := terminal
+	for _, mw := range slices.Backward(middleware) {
+		handler = mw(handler)
+	}
+	return handler
+}
+
+// clientHandler は、ストリーミングでない呼び出しを Generate、ストリーミングの呼び出しを GenTextStream で処理する Handler を返します。
+func clientHandler(generate func(context.Context, GenTextParams) (*GenTextResponse, error),
+	stream func(context.Context, GenTextParams) iter.Seq2[StreamEvent, error]) Handler {
+	return func(ctx context.Context, req GenTextRequest) iter.Seq2[StreamEvent, error] {
+		if req.Stream {
+			return stream(ctx, req.Params)
+		}
+		return func(yield func(StreamEvent, error) bool) {
+			res, err := generate(ctx, req.Params)
+			if err != nil {
+				yield(StreamEvent{}, err)
+				return
+			}
+			yield(StreamEvent{
+				Done:         true,
+				FinishReason: res.FinishReason,
+				Usage:        res.Usage,
+				Response:     res,
+			}, nil)
+		}
+	}
+}
+
+// collectResponse は、Handler が返したイベントから最終イベントのレスポンスを取り出します。
+func collectResponse(events iter.Seq2[StreamEvent, error]) (*GenTextResponse, error) {
+	var res *GenTextResponse
+	for event, err := range events {
+		if err != nil {
+			return nil, err
+		}
+		if event.Done && event.Response != nil {
+			res = event.Response
+		}
+	}
+	if res == nil {
+		return nil, errNoResponse
+	}
+	return res, nil
+}
+
+// middlewareClient は、NewClient で作成したクライアントに Config.Middleware を適用する LLMWrapper です。
+// 埋め込みベクトル、トークン数の計算、コンテキストキャッシュの呼び出しには Middleware を適用せず、そのまま委譲します。
+type middlewareClient struct {
+	client  LLMWrapper
+	handler Handler
+}
+
+// withMiddleware は、Middleware が指定されている場合に、クライアントを middlewareClient で包みます。
+func withMiddleware(client LLMWrapper, middleware []Middleware) LLMWrapper {
+	if len(middleware) == 0 {
+		return client
+	}
+	return &middlewareClient{
+		client:  client,
+		handler: chainMiddleware(clientHandler(client.Generate, client.GenTextStream), middleware),
+	}
+}
+
+// GenText は、Middleware を適用してテキストを生成します。
+func (c *middlewareClient) GenText(params GenTextParams) (string, error, int) {
+	return c.GenTextContext(context.Background(), params)
+}
+
+// GenTextContext は、コンテキストを指定し、Middleware を適用してテキストを生成します。
+func (c *middlewareClient) GenTextContext(ctx context.Context, params GenTextParams) (string, error, int) {
+	res, err := c.Generate(ctx, params)
+	if err != nil {
+		return "", err, 0
+	}
+	return res.Text, nil, res.Usage.TotalTokens
+}
+
+// Generate は、Middleware を適用してテキストを生成します。
+func (c *middlewareClient) Generate(ctx context.Context, params GenTextParams) (*GenTextResponse, error) {
+	return collectResponse(c.handler(ctx, GenTextRequest{Params: params}))
+}
+
+// GenTextStream は、Middleware を適用し、生成されたテキストを逐次返します。
+func (c *middlewareClient) GenTextStream(ctx context.Context, params GenTextParams) iter.Seq2[StreamEvent, error] {
+	return c.handler(ctx, GenTextRequest{Params: params, Stream: true})
+}
+
+// Embed は、包んでいるクライアントで埋め込みベクトルを生成します。
+func (c *middlewareClient) Embed(ctx context.Context, params EmbedParams) (*EmbedResponse, error) {
+	embedder, ok := c.client.(Embedder)
+	if !ok {
+		return nil, fmt.Errorf("%w: provider %s", ErrEmbeddingNotSupported, params.Model.GetProvider())
+	}
+	return embedder.Embed(ctx, params)
+}
+
+// CountTokens は、包んでいるクライアントで入力トークン数を計算します。
+func (c *middlewareClient) CountTokens(ctx context.Context, params GenTextParams) (int, error) {
+	counter, ok := c.client.(TokenCounter)
+	if !ok {
+		return 0, fmt.Errorf("%w: provider %s", ErrTokenCountingNotSupported, params.Model.GetProvider())
+	}
+	return counter.CountTokens(ctx, params)
+}
+
+// cacheManager は、包んでいるクライアントのコンテキストキャッシュの管理を返します。
+func (c *middlewareClient) cacheManager() (CacheManager, error) {
+	manager, ok := c.client.(CacheManager)
+	if !ok {
+		return nil, ErrCacheManagementNotSupported
+	}
+	return manager, nil
+}
+
+// CreateCache は、包んでいるクライアントでコンテキストキャッシュを作成します。
+func (c *middlewareClient) CreateCache(ctx context.Context, params CachedContentParams) (*CachedContent, error) {
+	manager, err := c.cacheManager()
+	if err != nil {
+		return nil, err
+	}
+	return manager.CreateCache(ctx, params)
+}
+
+// ListCaches は、包んでいるクライアントのコンテキストキャッシュの一覧を返します。
+func (c *middlewareClient) ListCaches(ctx context.Context) ([]CachedContent, error) {
+	manager, err := c.cacheManager()
+	if err != nil {
+		return nil, err
+	}
+	return manager.ListCaches(ctx)
+}
+
+// DeleteCache は、包んでいるクライアントでコンテキストキャッシュを削除します。
+func (c *middlewareClient) DeleteCache(ctx context.Context, name string) error {
+	manager, err := c.cacheManager()
+	if err != nil {
+		return err
+	}
+	return manager.DeleteCache(ctx, name)
+}
+
+// Use は、すべてのテキスト生成の呼び出しに適用する Middleware を追加します。
+// Config.Middleware の後に、追加した順に内側へ適用されます。フォールバックを含む呼び出し全体に一度だけ適用されます。
+// クライアントの使用を開始する前に呼び出してください。
+func (c *UnifiedClient) Use(middleware ...Middleware) {
+	c.middleware = append(c.middleware, middleware...)
+	c.handler = chainMiddleware(clientHandler(c.generateChain, c.streamChain), c.middleware)
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// CacheTarget は、プロンプトキャッシュのブレークポイントを置く対象を表す型です。
+type CacheTarget string
+
+const (
+	// CacheTargetSystem は、システムプロンプトまでをキャッシュします。
+	CacheTargetSystem CacheTarget = "system"
+	// CacheTargetTools は、ツール定義までをキャッシュします。
+	CacheTargetTools CacheTarget = "tools"
+	// CacheTargetMessage は、指定したメッセージまでの会話をキャッシュします。
+	CacheTargetMessage CacheTarget = "message"
+)
+
+// CacheBreakpoint は、プロンプトキャッシュのブレークポイントを表す構造体です。
+// ブレークポイントより前のプロンプト（ツール定義、システムプロンプト、メッセージの順）がキャッシュされます。
+type CacheBreakpoint struct {
+	// Target は、ブレークポイントを置く対象です。
+	Target CacheTarget `json:"target"`
+	// MessageIndex は、Target が CacheTargetMessage の場合に、Messages 内のインデックスを指定します。
+	// Messages が空で Prompt を使用する場合は、0 がプロンプトを表します。
+	MessageIndex int `json:"message_index,omitempty"`
+}
+
+// CacheSystemPrompt は、システムプロンプトにブレークポイントを置きます。
+func CacheSystemPrompt() CacheBreakpoint {
+	return CacheBreakpoint{Target: CacheTargetSystem}
+}
+
+// CacheTools は、ツール定義にブレークポイントを置きます。
+func CacheTools() CacheBreakpoint {
+	return CacheBreakpoint{Target: CacheTargetTools}
+}
+
+// CacheMessage は、指定したインデックスのメッセージにブレークポイントを置きます。
+func CacheMessage(index int) CacheBreakpoint {
+	return CacheBreakpoint{Target: CacheTargetMessage, MessageIndex: index}
+}
+
+// CachedContentParams は、Geminiのコンテキストキャッシュの作成に必要なパラメータを表す構造体です。
+type CachedContentParams struct {
+	// Model は、キャッシュを使用するモデルです。キャッシュは作成したモデルでのみ使用できます。
+	Model Model `json:"model"`
+	// DisplayName は、キャッシュの表示名です。
+	DisplayName string `json:"display_name,omitempty"`
+	// SystemPrompt は、キャッシュに含めるシステム指示です。
+	SystemPrompt string `json:"system_prompt,omitempty"`
+	// Messages は、キャッシュに含める会話やドキュメントです。
+	Messages []Message `json:"messages,omitempty"`
+	// Tools は、キャッシュに含めるツールの定義です。
+	Tools []Tool `json:"tools,omitempty"`
+	// TTL は、キャッシュの有効期間です。0の場合はプロバイダの既定値（1時間）を使用します。
+	TTL time.Duration `json:"ttl,omitempty"`
+}
+
+// CachedContent は、作成済みのコンテキストキャッシュを表す構造体です。
+type CachedContent struct {
+	// Name は、GenTextParams.CachedContent に指定するキャッシュの名前です（例: cachedContents/abc123）。
+	Name        string    `json:"name"`
+	DisplayName string    `json:"display_name,omitempty"`
+	Model       Model     `json:"model"`
+	CreateTime  time.Time `json:"create_time"`
+	UpdateTime  time.Time `json:"update_time"`
+	ExpireTime  time.Time `json:"expire_time"`
+	// TokenCount は、キャッシュされたトークン数です。
+	TokenCount int `json:"token_count"`
+}
+
+// CacheManager は、コンテキストキャッシュの管理をサポートするプロバイダのインターフェースです。
+// 現在はGeminiのクライアントが実装しています。
+type CacheManager interface {
+	// CreateCache は、コンテキストキャッシュを作成します。
+	CreateCache(ctx context.Context, params CachedContentParams) (*CachedContent, error)
+	// ListCaches は、作成済みのコンテキストキャッシュの一覧を返します。
+	ListCaches(ctx context.Context) ([]CachedContent, error)
+	// DeleteCache は、コンテキストキャッシュを削除します。
+	DeleteCache(ctx context.Context, name string) error
+}
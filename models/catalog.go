@@ -0,0 +1,204 @@
+package models
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Capabilities は、モデルが対応している機能を表す構造体です。
+type Capabilities struct {
+	// Vision は、画像やドキュメントの入力に対応しているかどうかです。
+	Vision bool `json:"vision"`
+	// Tools は、ツール呼び出しに対応しているかどうかです。
+	Tools bool `json:"tools"`
+	// JSONSchema は、JSON Schemaによる構造化出力に対応しているかどうかです。
+	JSONSchema bool `json:"json_schema"`
+	// Reasoning は、推論（思考）を行うモデルかどうかです。
+	Reasoning bool `json:"reasoning"`
+	// Streaming は、ストリーミングに対応しているかどうかです。
+	Streaming bool `json:"streaming"`
+	// Embedding は、埋め込みベクトルの生成に使用するモデルかどうかです。
+	Embedding bool `json:"embedding"`
+}
+
+// ModelInfo は、モデルの情報を表す構造体です。
+type ModelInfo struct {
+	// ID は、このライブラリで使用するモデル名です。
+	ID Model `json:"id"`
+	// Provider は、モデルを提供するプロバイダです。
+	Provider Provider `json:"provider"`
+	// APIID は、プロバイダのAPIに送信するモデル名です。空の場合は ID を使用します。
+	APIID string `json:"api_id,omitempty"`
+	// Aliases は、ID の代わりに使用できるモデル名です。
+	Aliases []Model `json:"aliases,omitempty"`
+	// ContextWindow は、入力と出力を合わせた最大トークン数です。
+	ContextWindow int `json:"context_window"`
+	// MaxOutputTokens は、出力の最大トークン数です。0の場合は制限を確認しません。
+	MaxOutputTokens int `json:"max_output_tokens,omitempty"`
+	// Capabilities は、モデルが対応している機能です。
+	Capabilities Capabilities `json:"capabilities"`
+}
+
+// apiID は、プロバイダのAPIに送信するモデル名を返します。
+func (info ModelInfo) apiID() string {
+	if info.APIID != "" {
+		return info.APIID
+	}
+	return string(info.ID)
+}
+
+var (
+	visionCapabilities    = Capabilities{Vision: true, Tools: true, JSONSchema: true, Streaming: true}
+	reasoningCapabilities = Capabilities{Vision: true, Tools: true, JSONSchema: true, Reasoning: true, Streaming: true}
+	embeddingCapabilities = Capabilities{Embedding: true}
+)
+
+// defaultModels は、既定で登録されるモデルの一覧です。
+var defaultModels = []ModelInfo{
+	// OpenAI
+	{ID: ModelGPT4o, Provider: ProviderOpenAI, ContextWindow: 128000, MaxOutputTokens: 16384, Capabilities: visionCapabilities},
+	{ID: ModelGPT4, Provider: ProviderOpenAI, ContextWindow: 8192, MaxOutputTokens: 8192, Capabilities: Capabilities{Tools: true, Streaming: true}},
+	{ID: ModelGPT35Turbo, Provider: ProviderOpenAI, ContextWindow: 16385, MaxOutputTokens: 4096, Capabilities: Capabilities{Tools: true, Streaming: true}},
+	{ID: ModelO3Mini, Provider: ProviderOpenAI, APIID: "o3-mini", Aliases: []Model{"o3-mini"}, ContextWindow: 200000, MaxOutputTokens: 100000,
+		Capabilities: Capabilities{Tools: true, JSONSchema: true, Reasoning: true, Streaming: true}},
+	{ID: ModelO4Mini, Provider: ProviderOpenAI, Aliases: []Model{"o4-mini"}, ContextWindow: 200000, MaxOutputTokens: 100000, Capabilities: reasoningCapabilities},
+	{ID: Model4_1Nano, Provider: ProviderOpenAI, Aliases: []Model{"gpt-4.1-nano"}, ContextWindow: 1047576, MaxOutputTokens: 32768, Capabilities: visionCapabilities},
+	{ID: ModelO3, Provider: ProviderOpenAI, Aliases: []Model{"o3"}, ContextWindow: 200000, MaxOutputTokens: 100000, Capabilities: reasoningCapabilities},
+
+	{ID: ModelTextEmbedding3Small, Provider: ProviderOpenAI, ContextWindow: 8191, Capabilities: embeddingCapabilities},
+	{ID: ModelTextEmbedding3Large, Provider: ProviderOpenAI, ContextWindow: 8191, Capabilities: embeddingCapabilities},
+	{ID: ModelTextEmbeddingAda002, Provider: ProviderOpenAI, ContextWindow: 8191, Capabilities: embeddingCapabilities},
+
+	// Anthropic（構造化出力はツール呼び出しで実現します）
+	{ID: ModelClaude3Opus, Provider: ProviderAnthropic, APIID: "claude-3-opus-latest", ContextWindow: 200000, MaxOutputTokens: 4096, Capabilities: visionCapabilities},
+	{ID: ModelClaude37Sonnet, Provider: ProviderAnthropic, APIID: "claude-3-7-sonnet-latest", Aliases: []Model{"claude-3-7-sonnet"}, ContextWindow: 200000, MaxOutputTokens: 64000, Capabilities: reasoningCapabilities},
+	{ID: ModelClaude3Haiku, Provider: ProviderAnthropic, APIID: "claude-3-5-haiku-latest", Aliases: []Model{"claude-3-5-haiku"}, ContextWindow: 200000, MaxOutputTokens: 8192, Capabilities: visionCapabilities},
+
+	// Gemini
+	{ID: ModelGemini20Flash, Provider: ProviderGemini, ContextWindow: 1048576, MaxOutputTokens: 8192, Capabilities: visionCapabilities},
+	{ID: ModelGemini20Pro, Provider: ProviderGemini, ContextWindow: 2097152, MaxOutputTokens: 8192, Capabilities: visionCapabilities},
+	{ID: ModelGemini25FlashPreview, Provider: ProviderGemini, ContextWindow: 1048576, MaxOutputTokens: 65536, Capabilities: reasoningCapabilities},
+	{ID: ModelGemini25ProPreview, Provider: ProviderGemini, ContextWindow: 1048576, MaxOutputTokens: 65536, Capabilities: reasoningCapabilities},
+	{ID: ModelGemini25Pro, Provider: ProviderGemini, ContextWindow: 1048576, MaxOutputTokens: 65536, Capabilities: reasoningCapabilities},
+
+	{ID: ModelGeminiEmbedding001, Provider: ProviderGemini, ContextWindow: 2048, Capabilities: embeddingCapabilities},
+	{ID: ModelTextEmbedding004, Provider: ProviderGemini, ContextWindow: 2048, Capabilities: embeddingCapabilities},
+}
+
+// ModelRegistry は、モデルの情報を管理するレジストリです。複数のゴルーチンから安全に使用できます。
+type ModelRegistry struct {
+	mu     sync.RWMutex
+	models []ModelInfo
+	names  map[Model]int // ID、エイリアス、APIIDから models のインデックスへの対応
+}
+
+// newModelRegistry は、既定のモデルを登録したレジストリを作成します。
+func newModelRegistry() *ModelRegistry {
+	r := &ModelRegistry{names: make(map[Model]int)}
+	for _, info := range defaultModels {
+		r.Register(info)
+	}
+	return r
+}
+
+// Register は、モデルの情報を登録します。同じ ID のモデルが登録されている場合は上書きします。
+func (r *ModelRegistry) Register(info ModelInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i, ok := r.names[info.ID]
+	if ok && r.models[i].ID == info.ID {
+		r.models[i] = info
+	} else {
+		i = len(r.models)
+		r.models = append(r.models, info)
+	}
+
+	r.names[info.ID] = i
+	r.names[Model(info.apiID())] = i
+	for _, alias := range info.Aliases {
+		r.names[alias] = i
+	}
+}
+
+// Lookup は、モデル名（ID、エイリアス、APIのモデル名のいずれか）からモデルの情報を返します。
+// 一致するモデルがない場合は、日付やバージョンの付いたモデル名（例: gpt-4o-2024-08-06）として、
+// 最も長く一致する接頭辞のモデルの情報を返します。
+func (r *ModelRegistry) Lookup(model Model) (ModelInfo, bool) {
+	info, _, ok := r.lookup(model)
+	return info, ok
+}
+
+// lookup は、モデルの情報と、モデル名が登録されている名前と完全に一致したかどうかを返します。
+// 日付やバージョンの付いたモデル名として接頭辞で一致した場合、exact は false です。
+func (r *ModelRegistry) lookup(model Model) (info ModelInfo, exact bool, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if i, ok := r.names[model]; ok {
+		return r.models[i], true, true
+	}
+
+	found, longest := -1, 0
+	for name, i := range r.names {
+		if len(name) > longest && isSnapshotOf(model, name) {
+			found, longest = i, len(name)
+		}
+	}
+	if found < 0 {
+		return ModelInfo{}, false, false
+	}
+	return r.models[found], false, true
+}
+
+// Models は、登録されているすべてのモデルの情報を登録順に返します。
+func (r *ModelRegistry) Models() []ModelInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.models)
+}
+
+// ModelsByProvider は、指定したプロバイダのモデルの情報を登録順に返します。
+func (r *ModelRegistry) ModelsByProvider(provider Provider) []ModelInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	infos := []ModelInfo{}
+	for _, info := range r.models {
+		if info.Provider == provider {
+			infos = append(infos, info)
+		}
+	}
+	return infos
+}
+
+// isSnapshotOf は、model が base に日付やバージョンを付けたモデル名（例: base-2024-08-06、base-001）かどうかを返します。
+func isSnapshotOf(model, base Model) bool {
+	prefix := string(base) + "-"
+	rest, ok := strings.CutPrefix(string(model), prefix)
+	return ok && rest != "" && rest[0] >= '0' && rest[0] <= '9'
+}
+
+// defaultRegistry は、Model のメソッドやプロバイダが使用するレジストリです。
+var defaultRegistry = newModelRegistry()
+
+// DefaultModelRegistry は、モデルの判定や検証に使用される共有のレジストリを返します。
+// このレジストリに Register したモデルは、UnifiedClient のルーティングや各プロバイダの検証に反映されます。
+func DefaultModelRegistry() *ModelRegistry {
+	return defaultRegistry
+}
+
+// Info は、レジストリに登録されているモデルの情報を返します。
+func (m Model) Info() (ModelInfo, bool) {
+	return defaultRegistry.Lookup(m)
+}
+
+// APIID は、プロバイダのAPIに送信するモデル名を返します。
+// ID やエイリアスの場合は登録されているAPIのモデル名を返し、日付やバージョンの付いたモデル名や未登録のモデル名はそのまま返します。
+func (m Model) APIID() string {
+	if info, exact, ok := defaultRegistry.lookup(m); ok && exact {
+		return info.apiID()
+	}
+	return string(m)
+}
@@ -0,0 +1,54 @@
+package models
+
+import "testing"
+
+func TestModelLookupAndAPIID(t *testing.T) {
+	tests := []struct {
+		name      string
+		model     Model
+		wantFound bool
+		wantID    Model
+		wantAPIID string
+	}{
+		{name: "ID", model: ModelGPT4o, wantFound: true, wantID: ModelGPT4o, wantAPIID: "gpt-4o"},
+		{name: "ID with API ID", model: ModelClaude37Sonnet, wantFound: true, wantID: ModelClaude37Sonnet, wantAPIID: "claude-3-7-sonnet-latest"},
+		{name: "alias", model: "claude-3-7-sonnet", wantFound: true, wantID: ModelClaude37Sonnet, wantAPIID: "claude-3-7-sonnet-latest"},
+		{name: "alias of haiku", model: "claude-3-5-haiku", wantFound: true, wantID: ModelClaude3Haiku, wantAPIID: "claude-3-5-haiku-latest"},
+		{name: "alias equal to API ID", model: "o3-mini", wantFound: true, wantID: ModelO3Mini, wantAPIID: "o3-mini"},
+		{name: "API ID", model: "claude-3-opus-latest", wantFound: true, wantID: ModelClaude3Opus, wantAPIID: "claude-3-opus-latest"},
+		{name: "dated snapshot", model: "gpt-4o-2024-08-06", wantFound: true, wantID: ModelGPT4o, wantAPIID: "gpt-4o-2024-08-06"},
+		{name: "snapshot of alias", model: "claude-3-7-sonnet-20250219", wantFound: true, wantID: ModelClaude37Sonnet, wantAPIID: "claude-3-7-sonnet-20250219"},
+		{name: "numbered version", model: "text-embedding-004-001", wantFound: true, wantID: ModelTextEmbedding004, wantAPIID: "text-embedding-004-001"},
+		{name: "different model with same prefix", model: "gpt-4o-mini", wantFound: false, wantAPIID: "gpt-4o-mini"},
+		{name: "unknown", model: "my-fine-tuned-model", wantFound: false, wantAPIID: "my-fine-tuned-model"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, ok := DefaultModelRegistry().Lookup(tt.model)
+			if ok != tt.wantFound {
+				t.Fatalf("Lookup(%q) found = %t, want %t", tt.model, ok, tt.wantFound)
+			}
+			if ok && info.ID != tt.wantID {
+				t.Errorf("Lookup(%q).ID = %q, want %q", tt.model, info.ID, tt.wantID)
+			}
+			if got := tt.model.APIID(); got != tt.wantAPIID {
+				t.Errorf("APIID(%q) = %q, want %q", tt.model, got, tt.wantAPIID)
+			}
+		})
+	}
+}
+
+func TestRegistryRegisterOverwrites(t *testing.T) {
+	r := newModelRegistry()
+	r.Register(ModelInfo{ID: "custom-model", Provider: ProviderOpenAI, APIID: "custom-model-v2", ContextWindow: 1000})
+	r.Register(ModelInfo{ID: "custom-model", Provider: ProviderOpenAI, APIID: "custom-model-v2", ContextWindow: 2000})
+
+	info, ok := r.Lookup("custom-model-v2")
+	if !ok || info.ContextWindow != 2000 {
+		t.Fatalf("Lookup() = %+v, %t, want the overwritten model", info, ok)
+	}
+	if n := len(r.ModelsByProvider(ProviderOpenAI)); n != len(newModelRegistry().ModelsByProvider(ProviderOpenAI))+1 {
+		t.Errorf("got %d OpenAI models after overwriting, want one more than the defaults", n)
+	}
+}
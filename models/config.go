@@ -1,5 +1,51 @@
 package models
 
+import "time"
+
 type Config struct {
 	MaxToken int
+	// Retry は、一時的なエラー（429や5xxなど）に対する再試行の設定です。
+	// ゼロ値の場合、OpenAIとAnthropicは DefaultRetryPolicy を使用し、Geminiは再試行しません。
+	Retry RetryPolicy
+	// KeyPool は、プロバイダごとに複数のAPIキーを使用する場合の設定です（UnifiedClient のみ）。
+	KeyPool KeyPoolConfig
+	// Pricing は、レスポンスの料金の計算に使用する料金表です。nil の場合は DefaultPricingCatalog を使用します。
+	Pricing *PricingCatalog
+	// CheckContextWindow は、リクエストの前に入力トークン数を計算し、最大出力トークン数との合計が
+	// モデルのコンテキストウィンドウを超える場合に ErrContextLengthExceeded を返すかどうかです。
+	// AnthropicとGeminiでは、トークン数の計算のためにAPIリクエストが1回増えます。
+	CheckContextWindow bool
+	// Middleware は、すべてのテキスト生成の呼び出しに適用する Middleware です。先頭の Middleware が最も外側で実行されます。
+	// UnifiedClient では、フォールバックを含む呼び出し全体に一度だけ適用されます。
+	Middleware []Middleware
+	// Tracing は、OpenTelemetry によるトレースの設定です。
+	Tracing TracingConfig
+	// Metrics は、テキスト生成の呼び出しを記録する MetricsRecorder です（例: wrapper.NewMetrics で作成した Metrics）。nil の場合は記録しません。
+	Metrics MetricsRecorder
+}
+
+// RetryPolicy は、一時的なエラーに対する再試行の設定を表す構造体です。
+type RetryPolicy struct {
+	// MaxAttempts は、初回を含む最大試行回数です。0の場合は再試行ポリシーを使用しません。
+	MaxAttempts int
+	// BaseBackoff は、最初の再試行までの待機時間です。試行のたびに2倍になります。0の場合は500ミリ秒です。
+	BaseBackoff time.Duration
+	// MaxBackoff は、待機時間の上限です。0の場合は30秒です。
+	// Retry-After ヘッダーなどでプロバイダから待機時間が指定された場合は、そちらを優先します。
+	MaxBackoff time.Duration
+	// Jitter は、待機時間をランダムに短縮する割合（0〜1）です。複数のクライアントの再試行が集中するのを防ぎます。
+	Jitter float64
+	// SkipRateLimited は、レート制限（429）のエラーを再試行しない場合に true にします。
+	// 複数のAPIキーのプールでは、すぐに別のキーに切り替えるため、各キーのクライアントで true になります。
+	SkipRateLimited bool
+}
+
+// DefaultRetryPolicy は、推奨される再試行の設定を返します。
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseBackoff: 500 * time.Millisecond,
+		MaxBackoff:  30 * time.Second,
+		Jitter:      0.2,
+	}
 }
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// PartType は、メッセージを構成するパートの種類を表す型です。
+type PartType string
+
+const (
+	// PartTypeText は、テキストのパートを表します。
+	PartTypeText PartType = "text"
+	// PartTypeImage は、画像のパートを表します。
+	PartTypeImage PartType = "image"
+	// PartTypeDocument は、PDFなどのドキュメントのパートを表します。
+	PartTypeDocument PartType = "document"
+)
+
+// Part は、マルチパートメッセージの1つのパートを表す構造体です。
+// 画像やドキュメントは、Data（バイト列）または URL のいずれかで指定します。
+type Part struct {
+	// Type は、パートの種類です。
+	Type PartType `json:"type"`
+	// Text は、テキストパートの内容です。
+	Text string `json:"text,omitempty"`
+	// Data は、画像やドキュメントのバイト列です。JSONではbase64文字列として表現されます。
+	Data []byte `json:"data,omitempty"`
+	// URL は、画像やドキュメントのURLです。
+	URL string `json:"url,omitempty"`
+	// MIMEType は、画像やドキュメントのMIMEタイプです（例: image/png, application/pdf）。
+	MIMEType string `json:"mime_type,omitempty"`
+}
+
+// TextPart は、テキストのパートを作成します。
+func TextPart(text string) Part {
+	return Part{Type: PartTypeText, Text: text}
+}
+
+// ImagePart は、バイト列から画像のパートを作成します。MIMEタイプは内容から自動的に判定されます。
+func ImagePart(data []byte) Part {
+	return Part{Type: PartTypeImage, Data: data, MIMEType: http.DetectContentType(data)}
+}
+
+// ImagePartFromBase64 は、base64文字列から画像のパートを作成します。
+// "data:image/png;base64,..." 形式のデータURLも指定できます。
+func ImagePartFromBase64(encoded string) (Part, error) {
+	data, err := decodeBase64(encoded)
+	if err != nil {
+		return Part{}, err
+	}
+	return ImagePart(data), nil
+}
+
+// ImagePartFromURL は、URLから画像のパートを作成します。MIMEタイプはURLの拡張子から推測されます。
+func ImagePartFromURL(u string) Part {
+	return Part{Type: PartTypeImage, URL: u, MIMEType: mimeTypeFromURL(u)}
+}
+
+// DocumentPart は、バイト列からドキュメント（PDF）のパートを作成します。
+func DocumentPart(data []byte) Part {
+	return Part{Type: PartTypeDocument, Data: data, MIMEType: http.DetectContentType(data)}
+}
+
+// DocumentPartFromURL は、URLからドキュメント（PDF）のパートを作成します。
+func DocumentPartFromURL(u string) Part {
+	mimeType := mimeTypeFromURL(u)
+	if mimeType == "" {
+		mimeType = "application/pdf"
+	}
+	return Part{Type: PartTypeDocument, URL: u, MIMEType: mimeType}
+}
+
+// Base64 は、パートのデータをbase64文字列で返します。
+func (p Part) Base64() string {
+	return base64.StdEncoding.EncodeToString(p.Data)
+}
+
+// DataURL は、パートのデータを "data:<MIMEタイプ>;base64,..." 形式のデータURLで返します。
+func (p Part) DataURL() string {
+	return fmt.Sprintf("data:%s;base64,%s", p.MIMEType, p.Base64())
+}
+
+// ContentParts は、メッセージの内容をパートのスライスとして返します。
+// Content が空でない場合は、先頭のテキストパートとして含まれます。
+func (m Message) ContentParts() []Part {
+	parts := make([]Part, 0, len(m.Parts)+1)
+	if m.Content != "" {
+		parts = append(parts, TextPart(m.Content))
+	}
+	return append(parts, m.Parts...)
+}
+
+// HasMedia は、メッセージに画像やドキュメントが含まれているかどうかを返します。
+func (m Message) HasMedia() bool {
+	for _, part := range m.Parts {
+		if part.Type == PartTypeImage || part.Type == PartTypeDocument {
+			return true
+		}
+	}
+	return false
+}
+
+// Text は、メッセージに含まれるテキストを連結して返します。
+func (m Message) Text() string {
+	texts := []string{}
+	for _, part := range m.ContentParts() {
+		if part.Type == PartTypeText && part.Text != "" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// decodeBase64 は、base64文字列またはデータURLをデコードします。
+func decodeBase64(encoded string) ([]byte, error) {
+	if strings.HasPrefix(encoded, "data:") {
+		_, data, ok := strings.Cut(encoded, ",")
+		if !ok {
+			return nil, fmt.Errorf("invalid data URL")
+		}
+		encoded = data
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 data: %w", err)
+	}
+	return data, nil
+}
+
+// mimeTypeFromURL は、URLの拡張子からMIMEタイプを推測します。
+func mimeTypeFromURL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	mimeType, _, _ := strings.Cut(mime.TypeByExtension(path.Ext(parsed.Path)), ";")
+	return mimeType
+}
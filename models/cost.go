@@ -0,0 +1,111 @@
+package models
+
+import (
+	"maps"
+	"sync"
+)
+
+// CostReport は、CostTracker が集計した料金の内訳を表す構造体です。
+type CostReport struct {
+	// Total は、すべてのリクエストの料金の合計です。
+	Total Cost `json:"total"`
+	// Requests は、記録したリクエストの数です。
+	Requests int `json:"requests"`
+	// Unpriced は、料金が登録されていないモデルのため料金を計算できなかったリクエストの数です。
+	Unpriced int `json:"unpriced"`
+	// ByProvider は、プロバイダごとの料金です。
+	ByProvider map[Provider]Cost `json:"by_provider"`
+	// ByModel は、モデルごとの料金です。
+	// カタログに登録されたモデルは、プロバイダが返す日付付きのモデル名ではなく、カタログのモデルIDで集計します。
+	ByModel map[Model]Cost `json:"by_model"`
+	// ByTag は、呼び出し側が指定したタグごとの料金です。
+	ByTag map[string]Cost `json:"by_tag"`
+}
+
+// CostTracker は、レスポンスの料金をプロバイダ・モデル・タグごとに集計します。複数のゴルーチンから安全に使用できます。
+type CostTracker struct {
+	mu     sync.Mutex
+	report CostReport
+}
+
+// NewCostTracker は、新しい CostTracker を作成します。
+func NewCostTracker() *CostTracker {
+	t := &CostTracker{}
+	t.Reset()
+	return t
+}
+
+// Record は、テキスト生成のレスポンスの料金を記録します。
+func (t *CostTracker) Record(res *GenTextResponse, tags ...string) {
+	if res == nil {
+		return
+	}
+	t.record(res.Provider, res.Model, res.Cost, tags)
+}
+
+// RecordEmbedding は、埋め込みベクトル生成のレスポンスの料金を記録します。
+func (t *CostTracker) RecordEmbedding(res *EmbedResponse, tags ...string) {
+	if res == nil {
+		return
+	}
+	t.record(res.Provider, res.Model, res.Cost, tags)
+}
+
+func (t *CostTracker) record(provider Provider, model Model, cost *Cost, tags []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.report.Requests++
+	if cost == nil {
+		t.report.Unpriced++
+		return
+	}
+
+	t.report.Total.Add(*cost)
+	addCost(t.report.ByProvider, provider, *cost)
+	addCost(t.report.ByModel, costModel(model), *cost)
+	for _, tag := range tags {
+		addCost(t.report.ByTag, tag, *cost)
+	}
+}
+
+// costModel は、料金を集計するモデル名を返します。
+// レスポンスのモデル名は日付付きのスナップショット（例: gpt-4o-2024-08-06）であることが多いため、
+// カタログに登録されたモデルはモデルIDに正規化し、同じモデルの料金が分散しないようにします。
+func costModel(model Model) Model {
+	if info, ok := model.Info(); ok {
+		return info.ID
+	}
+	return model
+}
+
+// addCost は、マップのキーに対応する料金に加算します。
+func addCost[K comparable](costs map[K]Cost, key K, cost Cost) {
+	total := costs[key]
+	total.Add(cost)
+	costs[key] = total
+}
+
+// Report は、現在までに集計した料金のコピーを返します。
+func (t *CostTracker) Report() CostReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	report := t.report
+	report.ByProvider = maps.Clone(t.report.ByProvider)
+	report.ByModel = maps.Clone(t.report.ByModel)
+	report.ByTag = maps.Clone(t.report.ByTag)
+	return report
+}
+
+// Reset は、集計した料金を消去します。
+func (t *CostTracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.report = CostReport{
+		ByProvider: make(map[Provider]Cost),
+		ByModel:    make(map[Model]Cost),
+		ByTag:      make(map[string]Cost),
+	}
+}
@@ -0,0 +1,24 @@
+package models
+
+import "testing"
+
+func TestCostTrackerByModel(t *testing.T) {
+	tracker := NewCostTracker()
+	for _, model := range []Model{"gpt-4o-2024-08-06", "gpt-4o-2024-11-20", ModelGPT4o, "claude-3-7-sonnet-20250219", "my-fine-tuned-model"} {
+		tracker.Record(&GenTextResponse{Provider: ProviderOpenAI, Model: model, Cost: &Cost{Total: 1}})
+	}
+
+	report := tracker.Report()
+	want := map[Model]float64{ModelGPT4o: 3, ModelClaude37Sonnet: 1, "my-fine-tuned-model": 1}
+	if len(report.ByModel) != len(want) {
+		t.Errorf("ByModel = %v, want keys %v", report.ByModel, want)
+	}
+	for model, total := range want {
+		if got := report.ByModel[model].Total; got != total {
+			t.Errorf("ByModel[%q].Total = %v, want %v", model, got, total)
+		}
+	}
+	if report.Requests != 5 || report.Total.Total != 5 {
+		t.Errorf("Requests = %d, Total = %v, want 5 and 5", report.Requests, report.Total.Total)
+	}
+}
@@ -0,0 +1,46 @@
+package models
+
+import "context"
+
+// 利用可能な埋め込みモデルの定数
+const (
+	// OpenAI埋め込みモデル
+	ModelTextEmbedding3Small Model = "text-embedding-3-small"
+	ModelTextEmbedding3Large Model = "text-embedding-3-large"
+	ModelTextEmbeddingAda002 Model = "text-embedding-ada-002"
+
+	// Gemini埋め込みモデル
+	ModelGeminiEmbedding001 Model = "gemini-embedding-001"
+	ModelTextEmbedding004   Model = "text-embedding-004"
+)
+
+// EmbedParams は、埋め込みベクトルの生成に必要なパラメータを表す構造体です。
+type EmbedParams struct {
+	Model Model `json:"model"`
+	// Inputs は、埋め込みベクトルを生成するテキストです。
+	// プロバイダの上限を超える場合は、自動的に複数のリクエストに分割されます。
+	Inputs []string `json:"inputs"`
+	// Dimensions は、出力するベクトルの次元数です。0の場合はモデルの既定値を使用します。
+	Dimensions int `json:"dimensions,omitempty"`
+	// Tags は、UnifiedClient でコストを集計する際のタグです。
+	Tags []string `json:"tags,omitempty"`
+}
+
+// EmbedResponse は、埋め込みベクトルの生成結果を表す構造体です。
+type EmbedResponse struct {
+	// Embeddings は、Inputs と同じ順序で並んだ埋め込みベクトルです。
+	Embeddings [][]float32 `json:"embeddings"`
+	// Usage は、すべてのリクエストを合計したトークン使用量です。
+	// Gemini APIはトークン数を返さないため、0になる場合があります。
+	Usage    Usage    `json:"usage"`
+	Provider Provider `json:"provider"`
+	Model    Model    `json:"model"`
+	// Cost は、トークン使用量から計算した料金です。料金表にモデルが登録されていない場合は nil です。
+	Cost *Cost `json:"cost,omitempty"`
+}
+
+// Embedder は、埋め込みベクトルの生成をサポートするプロバイダのインターフェースです。
+type Embedder interface {
+	// Embed は、入力テキストの埋め込みベクトルを生成します。
+	Embed(ctx context.Context, params EmbedParams) (*EmbedResponse, error)
+}
@@ -18,3 +18,62 @@ var ErrEmptyMessages = errors.New("empty messages")
 
 // ErrAPIRequest は、APIリクエスト中にエラーが発生した場合に返されるエラーです。
 var ErrAPIRequest = errors.New("API request error")
+
+// ErrRequestCanceled は、コンテキストのキャンセルまたはデッドライン超過によりリクエストが中断された場合に返されるエラーです。
+// context.Canceled / context.DeadlineExceeded もラップされるため、errors.Is で判別できます。
+var ErrRequestCanceled = errors.New("request canceled")
+
+// ErrSchemaValidation は、構造化出力がJSON Schemaに適合しなかった場合に返されるエラーです。
+var ErrSchemaValidation = errors.New("response does not conform to schema")
+
+// ErrUnsupportedSchemaType は、構造化出力に使用できないGoの型からJSON Schemaを生成しようとした場合に返されるエラーです。
+var ErrUnsupportedSchemaType = errors.New("unsupported type for JSON schema")
+
+// ErrVisionNotSupported は、画像やドキュメントの入力をサポートしていないモデルにそれらを送信した場合に返されるエラーです。
+var ErrVisionNotSupported = errors.New("model does not support image or document input")
+
+// ErrUnsupportedContent は、プロバイダが受け付けられない形式のコンテンツが指定された場合に返されるエラーです。
+var ErrUnsupportedContent = errors.New("unsupported content")
+
+// ErrEmptyInputs は、埋め込みベクトルを生成する入力が空の場合に返されるエラーです。
+var ErrEmptyInputs = errors.New("empty inputs")
+
+// ErrEmbeddingNotSupported は、埋め込みベクトルの生成をサポートしていないプロバイダを使用した場合に返されるエラーです。
+var ErrEmbeddingNotSupported = errors.New("embeddings not supported")
+
+// ErrTokenCountingNotSupported は、トークン数の計算をサポートしていないプロバイダを使用した場合に返されるエラーです。
+var ErrTokenCountingNotSupported = errors.New("token counting not supported")
+
+// ErrUnsupportedParameter は、プロバイダやモデルがサポートしていないパラメータを指定した場合に返されるエラーです。
+var ErrUnsupportedParameter = errors.New("unsupported parameter")
+
+// ErrInvalidCacheBreakpoint は、キャッシュのブレークポイントの指定が不正な場合に返されるエラーです。
+var ErrInvalidCacheBreakpoint = errors.New("invalid cache breakpoint")
+
+// ErrCacheNotFound は、参照したコンテキストキャッシュが存在しないか、有効期限が切れている場合に返されるエラーです。
+// このエラーを受け取った場合は、キャッシュを再作成してください。
+var ErrCacheNotFound = errors.New("cached content not found or expired")
+
+// ErrCacheManagementNotSupported は、コンテキストキャッシュの管理をサポートしていないプロバイダを使用した場合に返されるエラーです。
+var ErrCacheManagementNotSupported = errors.New("context cache management not supported")
+
+// ErrNoAvailableKey は、APIキーのプールにリクエストに使用できるキーが残っていない場合に返されるエラーです。
+var ErrNoAvailableKey = errors.New("no available API key")
+
+// 以下は、ProviderError の分類を表すエラーです。errors.Is で判別できます。
+
+// ErrRateLimited は、レート制限やクォータの超過によりリクエストが拒否された場合のエラーです。
+var ErrRateLimited = errors.New("rate limited")
+
+// ErrAuthentication は、APIキーが無効であるか、権限が不足している場合のエラーです。
+var ErrAuthentication = errors.New("authentication failed")
+
+// ErrContextLengthExceeded は、入力がモデルのコンテキスト長を超えた場合のエラーです。
+var ErrContextLengthExceeded = errors.New("context length exceeded")
+
+// ErrContentFiltered は、プロバイダのコンテンツフィルタによりリクエストが拒否された場合や、プロンプトや応答がブロックされてテキストが返されなかった場合のエラーです。
+// 生成の途中で安全性の理由により停止した場合は、エラーではなく FinishReasonSafety が返されます。
+var ErrContentFiltered = errors.New("content filtered")
+
+// ErrProviderUnavailable は、プロバイダ側の障害や過負荷（5xx）によりリクエストが失敗した場合のエラーです。
+var ErrProviderUnavailable = errors.New("provider unavailable")
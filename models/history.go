@@ -0,0 +1,12 @@
+package models
+
+import "context"
+
+// HistoryStrategy は、会話履歴をモデルのコンテキストウィンドウに収まるように調整する戦略です。
+// GenTextParams.History に指定すると、UnifiedClient はリクエストを送信する前に、送信先のモデルごとに Messages を調整します。
+type HistoryStrategy interface {
+	// Fit は、入力に使用できるトークン数 budget に収まるように調整したメッセージを返します。
+	// budget は、モデルのコンテキストウィンドウから最大出力トークン数を引いた値です。コンテキストウィンドウが不明な場合は0です。
+	// params.Messages を変更してはいけません。
+	Fit(ctx context.Context, params GenTextParams, budget int) ([]Message, error)
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// KeySelection は、APIキーのプールからキーを選択する方法を表す型です。
+type KeySelection string
+
+const (
+	// KeySelectionRoundRobin は、キーを順番に使用します。
+	KeySelectionRoundRobin KeySelection = "round_robin"
+	// KeySelectionLeastRecentlyLimited は、レート制限を受けてから最も時間が経過したキーを優先して使用します。
+	KeySelectionLeastRecentlyLimited KeySelection = "least_recently_limited"
+)
+
+// KeyPoolConfig は、プロバイダごとのAPIキーのプールの設定を表す構造体です。
+type KeyPoolConfig struct {
+	// Selection は、キーの選択方法です。空の場合はラウンドロビンです。
+	Selection KeySelection
+	// RateLimitCooldown は、429を返したキーを除外する時間です。0の場合は30秒です。
+	// Retry-After ヘッダーなどでプロバイダから待機時間が指定された場合は、そちらを優先します。
+	RateLimitCooldown time.Duration
+	// AuthCooldown は、認証エラー（401/403）を返したキーを除外する時間です。0の場合は10分です。
+	AuthCooldown time.Duration
+}
+
+// KeyStats は、APIキーごとの使用状況を表す構造体です。
+type KeyStats struct {
+	// Key は、末尾のみを表示するようにマスクしたAPIキーです。
+	Key string `json:"key"`
+	// Requests は、このキーで送信したリクエストの数です。
+	Requests int64 `json:"requests"`
+	// Failures は、エラーになったリクエストの数です。
+	Failures int64 `json:"failures"`
+	// RateLimited は、レート制限（429）を受けた回数です。
+	RateLimited int64 `json:"rate_limited"`
+	// AuthFailures は、認証エラーを受けた回数です。
+	AuthFailures int64 `json:"auth_failures"`
+	// Usage は、このキーで成功したリクエストのトークン使用量の合計です。
+	Usage Usage `json:"usage"`
+	// LastUsed は、最後にこのキーを使用した時刻です。
+	LastUsed time.Time `json:"last_used"`
+	// LastLimited は、最後にレート制限を受けた時刻です。
+	LastLimited time.Time `json:"last_limited"`
+	// EjectedUntil は、このキーがプールから除外されている期限です。
+	EjectedUntil time.Time `json:"ejected_until"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// MetricsConfig は、wrapper.Metrics の設定です。
+type MetricsConfig struct {
+	// Namespace は、メトリクス名の接頭辞です。空の場合は "ai_wrapper" を使用します。
+	Namespace string
+	// ConstLabels は、すべてのメトリクスに付与する固定のラベルです（例: サービス名）。
+	ConstLabels map[string]string
+	// DurationBuckets は、リクエストの所要時間のヒストグラムのバケット（秒）です。空の場合は 0.1 秒から約 100 秒までの指数バケットを使用します。
+	DurationBuckets []float64
+	// TimeToFirstTokenBuckets は、最初のトークンまでの時間のヒストグラムのバケット（秒）です。空の場合は 0.05 秒から約 25 秒までの指数バケットを使用します。
+	TimeToFirstTokenBuckets []float64
+}
+
+// GenerationRecord は、1回のテキスト生成の呼び出しの計測結果です。
+type GenerationRecord struct {
+	// Provider は、リクエストを処理したプロバイダです。
+	Provider Provider
+	// Model は、リクエストで指定したモデルです（プロバイダが返す日付付きのモデルIDではありません）。
+	Model Model
+	// Stream は、ストリーミングの呼び出しかどうかです。
+	Stream bool
+	// Status は、成功した場合は "ok"、失敗した場合はエラーの分類（トレースの error.type と同じ値）です。
+	// 最終イベントの前に反復を中断したストリームは "canceled" です。
+	Status string
+	// Duration は、呼び出しの所要時間です。再試行した場合は、その待機時間も含みます。
+	Duration time.Duration
+	// TimeToFirstToken は、ストリーミングで最初のテキストを受け取るまでの時間です。受け取らなかった場合は0です。
+	TimeToFirstToken time.Duration
+	// Usage は、トークン使用量です。失敗した場合はゼロ値です。
+	Usage Usage
+}
+
+// MetricsRecorder は、テキスト生成の呼び出しの計測結果を記録するインターフェースです。
+// Config.Metrics に設定すると、各プロバイダのクライアントが呼び出しごとに RecordGeneration を呼び出します。
+// 複数のゴルーチンから同時に呼び出されるため、実装は並行に安全である必要があります。
+type MetricsRecorder interface {
+	RecordGeneration(record GenerationRecord)
+}
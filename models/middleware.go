@@ -0,0 +1,22 @@
+package models
+
+import (
+	"context"
+	"iter"
+)
+
+// GenTextRequest は、Middleware が受け取るテキスト生成のリクエストです。
+type GenTextRequest struct {
+	// Params は、テキスト生成のパラメータです。Middleware で変更してから次の Handler に渡せます。
+	Params GenTextParams
+	// Stream は、GenTextStream による呼び出しかどうかです。false の場合は Generate、GenText、GenTextContext による呼び出しです。
+	Stream bool
+}
+
+// Handler は、テキスト生成のリクエストを処理し、イベントを逐次返す関数です。
+// ストリーミングでない呼び出しでも、最後に Done が true で Response を含むイベントを返します。
+type Handler func(ctx context.Context, req GenTextRequest) iter.Seq2[StreamEvent, error]
+
+// Middleware は、Handler を包んで、ログ、マスキング、メトリクス、ポリシーなどの処理を追加する関数です。
+// next を呼び出さずにイベントを返すと、プロバイダへのリクエストを省略してキャッシュや固定の応答を返せます。
+type Middleware func(next Handler) Handler
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"context"
+	"iter"
 	"regexp"
 	"strings"
 
@@ -49,50 +51,70 @@ const (
 
 // ToOpenAIModel は、共通モデル型をOpenAI SDKのモデル型に変換します。
 func (m Model) ToOpenAIModel() shared.ChatModel {
-	switch m {
-	case ModelGPT4o:
-		return shared.ChatModelGPT4o
-	case ModelGPT4:
-		return shared.ChatModelGPT4
-	case ModelO3Mini:
-		return shared.ChatModelO3Mini
-	case ModelO4Mini:
-		return "o4-mini-2025-04-16"
-	case Model4_1Nano:
-		return "gpt-4.1-nano-2025-04-14"
-	case ModelO3:
-		return "o3-2025-04-16"
-	// case ModelGPT35Turbo:
-	// 	return shared.ChatModelGPT35Turbo
-	default:
-		return string(m)
-	}
+	return m.APIID()
 }
 
 // ToAnthropicModel は、共通モデル型をAnthropic SDKのモデル型に変換します。
 func (m Model) ToAnthropicModel() anthropic.Model {
-	switch m {
-	case ModelClaude3Opus:
-		return anthropic.ModelClaude3OpusLatest
-	case ModelClaude37Sonnet:
-		return anthropic.ModelClaude3_7SonnetLatest
-	case ModelClaude3Haiku:
-		return anthropic.ModelClaude3_5HaikuLatest
-
-	default:
-		return string(m)
+	return m.APIID()
+}
+
+// SupportsVision は、モデルが画像やドキュメントの入力をサポートしているかどうかを返します。
+// レジストリに登録されていないモデルは、モデル名から推定します。
+func (m Model) SupportsVision() bool {
+	if info, ok := m.Info(); ok {
+		return info.Capabilities.Vision
+	}
+
+	modelName := string(m)
+	for _, prefix := range []string{"gpt-3.5", "o1-mini", "o3-mini"} {
+		if strings.HasPrefix(modelName, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsReasoningModel は、モデルがOpenAIの推論モデル（oシリーズ）かどうかを返します。
+// 推論モデルは、temperature などの一部のサンプリングパラメータをサポートしていません。
+func (m Model) IsReasoningModel() bool {
+	if info, ok := m.Info(); ok {
+		return info.Provider == ProviderOpenAI && info.Capabilities.Reasoning
 	}
+	return reasoningModelPattern.MatchString(string(m))
 }
 
-// GetProvider はモデル名からプロバイダーを判定します
+var (
+	// reasoningModelPattern は、OpenAIの推論モデルの名前のパターンです (例: o1, o3-mini)
+	reasoningModelPattern = regexp.MustCompile(`^o\d+(-|$)`)
+	// geminiEmbeddingPattern は、Geminiの埋め込みモデルの名前のパターンです (例: text-embedding-004)
+	geminiEmbeddingPattern = regexp.MustCompile(`^text-embedding-\d{3}$`)
+	// openAIReasoningPrefixPattern は、OpenAIの推論モデルの接頭辞のパターンです (例: o1-, o3-)
+	openAIReasoningPrefixPattern = regexp.MustCompile(`^o\d+-`)
+)
+
+// GetProvider はモデル名からプロバイダーを判定します。
+// レジストリに登録されているモデルはその情報を使用し、登録されていないモデルはモデル名のパターンから推定します。
 func (m Model) GetProvider() Provider {
+	if info, ok := m.Info(); ok {
+		return info.Provider
+	}
+
 	modelName := string(m)
 
+	// Geminiの埋め込みモデルのパターン (例: text-embedding-004)
+	// OpenAIの埋め込みモデルと接頭辞が共通するため、先に判定します
+	if geminiEmbeddingPattern.MatchString(modelName) {
+		return ProviderGemini
+	}
+
 	// OpenAIモデルのパターン
 	// - "gpt-" で始まるモデル (例: gpt-4, gpt-3.5-turbo)
 	// - "o" + 数字 + "-" で始まるモデル (例: o1-, o2-, o3-, o4-)
+	// - "text-embedding-" で始まる埋め込みモデル (例: text-embedding-3-small)
 	if strings.HasPrefix(modelName, "gpt-") ||
-		regexp.MustCompile(`^o\d+-`).MatchString(modelName) {
+		strings.HasPrefix(modelName, "text-embedding-") ||
+		openAIReasoningPrefixPattern.MatchString(modelName) {
 		return ProviderOpenAI
 	}
 
@@ -120,32 +142,95 @@ const (
 	RoleAssistant Role = "assistant"
 	// RoleSystem は、システムからのメッセージを表します。
 	RoleSystem Role = "system"
+	// RoleTool は、ツールの実行結果を表すメッセージを表します。
+	RoleTool Role = "tool"
 )
 
 // Message は、LLMとのやり取りに使用するメッセージを表す構造体です。
 type Message struct {
 	Role    Role   `json:"role"`
 	Content string `json:"content"`
+	// Parts は、画像やドキュメントを含むマルチパートの内容です。
+	// Content と併用した場合、Content は先頭のテキストパートとして扱われます。
+	Parts []Part `json:"parts,omitempty"`
+	// ToolCalls は、アシスタントが要求したツール呼び出しです。
+	// 会話履歴にアシスタントの応答を含める際に、レスポンスの ToolCalls をそのまま設定します。
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID は、RoleTool のメッセージで、どのツール呼び出しに対する結果かを表します。
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	// ToolName は、RoleTool のメッセージで、実行したツールの名前を表します。
+	ToolName string `json:"tool_name,omitempty"`
 }
 
 // GenTextParams は、テキスト生成に必要なパラメータを表す構造体です。
 type GenTextParams struct {
 	// Model は、使用するLLMモデルです。
 	Model Model `json:"model"`
+	// Fallbacks は、Model が障害や一時的なエラーで失敗した場合に順に試行するモデルです（UnifiedClient のみ）。
+	Fallbacks []Model `json:"fallbacks,omitempty"`
+	// Tags は、UnifiedClient でコストを集計する際のタグです。
+	Tags []string `json:"tags,omitempty"`
 	// Prompt は、単一のプロンプトテキストです。
 	Prompt string `json:"prompt,omitempty"`
-	// CacheEnabled は、キャッシュを有効にするかどうかを指定します。
+	// SystemPrompt は、モデルへのシステム指示です。
+	// Messages に含まれる RoleSystem のメッセージよりも前に適用されます。
+	SystemPrompt string `json:"system_prompt,omitempty"`
+	// CacheEnabled は、プロンプトキャッシュを有効にするかどうかを指定します。
+	// Anthropicでは、CacheBreakpoints が未指定の場合、システムプロンプト、ツール定義、最後のメッセージにブレークポイントを置きます。
+	// OpenAIとGeminiは自動的にキャッシュを行うため、この設定による違いはありません。
 	CacheEnabled bool `json:"cache_enabled"`
+	// CacheBreakpoints は、プロンプトキャッシュのブレークポイントを明示的に指定します。
+	// 指定した場合は、CacheEnabled も有効として扱います。
+	CacheBreakpoints []CacheBreakpoint `json:"cache_breakpoints,omitempty"`
+	// CachedContent は、作成済みのGeminiのコンテキストキャッシュの名前です（Geminiのみ）。
+	// キャッシュを参照する場合、システムプロンプトとツールはキャッシュ側に含める必要があります。
+	CachedContent string `json:"cached_content,omitempty"`
 	// Messages は、会話履歴を表すメッセージのスライスです。
 	Messages []Message `json:"messages"`
+	// History は、Messages をモデルのコンテキストウィンドウに収まるように調整する戦略です（UnifiedClient のみ）。
+	// nil の場合は、Messages をそのまま送信します。
+	History HistoryStrategy `json:"-"`
+	// Tools は、モデルが呼び出すことのできるツールの定義です。
+	Tools []Tool `json:"tools,omitempty"`
+	// ToolChoice は、ツールの選択方法です。空の場合はプロバイダのデフォルト（auto）に従います。
+	ToolChoice ToolChoice `json:"tool_choice,omitempty"`
+	// ResponseFormat は、JSON Schemaに従った構造化出力を要求する場合に指定します。
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+
+	// 以下のサンプリングパラメータは省略可能です。未指定（nil）の場合はプロバイダのデフォルトに従います。
+	// プロバイダやモデルがサポートしていないパラメータを指定した場合は、ErrUnsupportedParameter が返されます。
+
+	// MaxTokens は、生成する最大トークン数です。0の場合は Config.MaxToken を使用します。
+	MaxTokens int `json:"max_tokens,omitempty"`
+	// Temperature は、出力のランダム性を制御します。
+	Temperature *float64 `json:"temperature,omitempty"`
+	// TopP は、核サンプリング（nucleus sampling）の累積確率です。
+	TopP *float64 `json:"top_p,omitempty"`
+	// TopK は、サンプリング対象とする上位トークン数です（Anthropic、Geminiのみ）。
+	TopK *int `json:"top_k,omitempty"`
+	// StopSequences は、生成を停止する文字列です。
+	StopSequences []string `json:"stop_sequences,omitempty"`
+	// Seed は、再現性のある出力を得るための乱数シードです（OpenAI、Geminiのみ）。
+	Seed *int64 `json:"seed,omitempty"`
+	// PresencePenalty は、既出のトークンに対するペナルティです（OpenAI、Geminiのみ）。
+	PresencePenalty *float64 `json:"presence_penalty,omitempty"`
+	// FrequencyPenalty は、出現頻度に応じたペナルティです（OpenAI、Geminiのみ）。
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
 }
 
-// GenTextResponse は、テキスト生成の結果を表す構造体です。
-type GenTextResponse struct {
-	// Text は、生成されたテキストです。
-	Text string
-	// Tokens は、使用されたトークン数です。
-	Tokens int
+// ResponseFormat は、構造化出力のJSON Schemaを表す構造体です。
+// OpenAIでは response_format（json_schema）、Geminiでは ResponseSchema、
+// Anthropicではツールの強制呼び出しとして送信され、生成されたJSONは Text に格納されます。
+type ResponseFormat struct {
+	// Name は、スキーマの名前です。英数字、アンダースコア、ハイフンのみ使用できます。
+	Name string `json:"name"`
+	// Description は、スキーマの説明です。
+	Description string `json:"description,omitempty"`
+	// Schema は、出力が従うべきJSON Schemaです。
+	Schema map[string]any `json:"schema"`
+	// Strict は、OpenAIのstrictモードを有効にするかどうかを指定します。
+	// 有効にする場合、すべてのプロパティが required で、additionalProperties が false である必要があります。
+	Strict bool `json:"strict,omitempty"`
 }
 
 // LLMWrapper は、LLMプロバイダとのやり取りを抽象化するインターフェースです。
@@ -153,4 +238,14 @@ type LLMWrapper interface {
 	// GenText は、指定されたパラメータに基づいてテキストを生成します。
 	// 生成されたテキスト、エラー、使用されたトークン数を返します。
 	GenText(params GenTextParams) (string, error, int)
+	// GenTextContext は、コンテキストを指定してテキストを生成します。
+	// コンテキストがキャンセルされた場合やデッドラインを超過した場合は ErrRequestCanceled を返します。
+	GenTextContext(ctx context.Context, params GenTextParams) (string, error, int)
+	// Generate は、指定されたパラメータに基づいてテキストを生成し、
+	// トークン使用量の内訳や終了理由を含む詳細なレスポンスを返します。
+	// GenText と GenTextContext は、このメソッドの薄いラッパーです。
+	Generate(ctx context.Context, params GenTextParams) (*GenTextResponse, error)
+	// GenTextStream は、生成されたテキストの差分を逐次返すイテレータを返します。
+	// 最後のイベントは Done が true となり、終了理由とトークン使用量を含みます。
+	GenTextStream(ctx context.Context, params GenTextParams) iter.Seq2[StreamEvent, error]
 }
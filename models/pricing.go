@@ -0,0 +1,191 @@
+package models
+
+import (
+	"maps"
+	"strings"
+	"sync"
+)
+
+// Pricing は、モデルの100万トークンあたりの料金（USD）を表す構造体です。
+type Pricing struct {
+	// InputPerMTok は、入力トークンの料金です。
+	InputPerMTok float64 `json:"input_per_mtok"`
+	// OutputPerMTok は、出力トークンの料金です。
+	OutputPerMTok float64 `json:"output_per_mtok"`
+	// CachedInputPerMTok は、キャッシュから読み込まれた入力トークンの料金です。0の場合は InputPerMTok を使用します。
+	CachedInputPerMTok float64 `json:"cached_input_per_mtok,omitempty"`
+	// CacheCreationPerMTok は、キャッシュへの書き込みに使用された入力トークンの料金です。0の場合は InputPerMTok を使用します。
+	CacheCreationPerMTok float64 `json:"cache_creation_per_mtok,omitempty"`
+	// ReasoningPerMTok は、推論（思考）トークンの料金です。0の場合は OutputPerMTok を使用します。
+	ReasoningPerMTok float64 `json:"reasoning_per_mtok,omitempty"`
+}
+
+// Cost は、トークン使用量から計算した料金（USD）の内訳を表す構造体です。
+type Cost struct {
+	// Input は、キャッシュを使用しなかった入力トークンの料金です。
+	Input float64 `json:"input"`
+	// CachedInput は、キャッシュから読み込まれた入力トークンの料金です。
+	CachedInput float64 `json:"cached_input"`
+	// CacheCreation は、キャッシュへの書き込みに使用された入力トークンの料金です。
+	CacheCreation float64 `json:"cache_creation"`
+	// Output は、推論トークンを除く出力トークンの料金です。
+	Output float64 `json:"output"`
+	// Reasoning は、推論（思考）トークンの料金です。
+	Reasoning float64 `json:"reasoning"`
+	// Total は、料金の合計です。
+	Total float64 `json:"total"`
+}
+
+// Add は、別の料金を加算します。
+func (c *Cost) Add(other Cost) {
+	c.Input += other.Input
+	c.CachedInput += other.CachedInput
+	c.CacheCreation += other.CacheCreation
+	c.Output += other.Output
+	c.Reasoning += other.Reasoning
+	c.Total += other.Total
+}
+
+// Cost は、トークン使用量の内訳から料金を計算します。
+func (p Pricing) Cost(usage Usage) Cost {
+	cachedInputPrice := p.CachedInputPerMTok
+	if cachedInputPrice == 0 {
+		cachedInputPrice = p.InputPerMTok
+	}
+	cacheCreationPrice := p.CacheCreationPerMTok
+	if cacheCreationPrice == 0 {
+		cacheCreationPrice = p.InputPerMTok
+	}
+	reasoningPrice := p.ReasoningPerMTok
+	if reasoningPrice == 0 {
+		reasoningPrice = p.OutputPerMTok
+	}
+
+	// InputTokens にはキャッシュの読み書きに使われたトークンが、OutputTokens には推論トークンが含まれます
+	uncachedInput := max(usage.InputTokens-usage.CachedInputTokens-usage.CacheCreationTokens, 0)
+	output := max(usage.OutputTokens-usage.ReasoningTokens, 0)
+
+	cost := Cost{
+		Input:         float64(uncachedInput) * p.InputPerMTok / 1e6,
+		CachedInput:   float64(usage.CachedInputTokens) * cachedInputPrice / 1e6,
+		CacheCreation: float64(usage.CacheCreationTokens) * cacheCreationPrice / 1e6,
+		Output:        float64(output) * p.OutputPerMTok / 1e6,
+		Reasoning:     float64(usage.ReasoningTokens) * reasoningPrice / 1e6,
+	}
+	cost.Total = cost.Input + cost.CachedInput + cost.CacheCreation + cost.Output + cost.Reasoning
+	return cost
+}
+
+// defaultPricing は、既定の料金表です（USD / 100万トークン）。
+// APIのレスポンスに含まれる日付付きのモデル名（例: gpt-4o-2024-08-06）は、PricingCatalog.Lookup で接頭辞から解決します。
+var defaultPricing = map[Model]Pricing{
+	// OpenAI
+	ModelGPT4o:      {InputPerMTok: 2.50, CachedInputPerMTok: 1.25, OutputPerMTok: 10},
+	"gpt-4o-mini":   {InputPerMTok: 0.15, CachedInputPerMTok: 0.075, OutputPerMTok: 0.60},
+	"gpt-4.1":       {InputPerMTok: 2, CachedInputPerMTok: 0.50, OutputPerMTok: 8},
+	"gpt-4.1-mini":  {InputPerMTok: 0.40, CachedInputPerMTok: 0.10, OutputPerMTok: 1.60},
+	"gpt-4.1-nano":  {InputPerMTok: 0.10, CachedInputPerMTok: 0.025, OutputPerMTok: 0.40},
+	ModelGPT4:       {InputPerMTok: 30, OutputPerMTok: 60},
+	ModelGPT35Turbo: {InputPerMTok: 0.50, OutputPerMTok: 1.50},
+	"o3":            {InputPerMTok: 10, CachedInputPerMTok: 2.50, OutputPerMTok: 40},
+	"o3-mini":       {InputPerMTok: 1.10, CachedInputPerMTok: 0.55, OutputPerMTok: 4.40},
+	"o4-mini":       {InputPerMTok: 1.10, CachedInputPerMTok: 0.275, OutputPerMTok: 4.40},
+
+	ModelTextEmbedding3Small: {InputPerMTok: 0.02},
+	ModelTextEmbedding3Large: {InputPerMTok: 0.13},
+	ModelTextEmbeddingAda002: {InputPerMTok: 0.10},
+
+	// Anthropic（ModelClaude3Haiku は Claude 3.5 Haiku を呼び出すため、レスポンスのモデル名で解決されます）
+	ModelClaude3Opus:    {InputPerMTok: 15, CachedInputPerMTok: 1.50, CacheCreationPerMTok: 18.75, OutputPerMTok: 75},
+	ModelClaude37Sonnet: {InputPerMTok: 3, CachedInputPerMTok: 0.30, CacheCreationPerMTok: 3.75, OutputPerMTok: 15},
+	"claude-3-7-sonnet": {InputPerMTok: 3, CachedInputPerMTok: 0.30, CacheCreationPerMTok: 3.75, OutputPerMTok: 15},
+	"claude-3-5-sonnet": {InputPerMTok: 3, CachedInputPerMTok: 0.30, CacheCreationPerMTok: 3.75, OutputPerMTok: 15},
+	"claude-3-5-haiku":  {InputPerMTok: 0.80, CachedInputPerMTok: 0.08, CacheCreationPerMTok: 1, OutputPerMTok: 4},
+	ModelClaude3Haiku:   {InputPerMTok: 0.25, CachedInputPerMTok: 0.03, CacheCreationPerMTok: 0.30, OutputPerMTok: 1.25},
+
+	// Gemini（実験版のモデルは無料です）
+	ModelGemini20Flash:        {InputPerMTok: 0.10, CachedInputPerMTok: 0.025, OutputPerMTok: 0.40},
+	"gemini-2.0-flash-lite":   {InputPerMTok: 0.075, OutputPerMTok: 0.30},
+	ModelGemini25FlashPreview: {InputPerMTok: 0.15, CachedInputPerMTok: 0.0375, OutputPerMTok: 0.60, ReasoningPerMTok: 3.50},
+	ModelGemini25ProPreview:   {InputPerMTok: 1.25, CachedInputPerMTok: 0.31, OutputPerMTok: 10},
+	ModelGemini25Pro:          {},
+
+	ModelGeminiEmbedding001: {InputPerMTok: 0.15},
+	ModelTextEmbedding004:   {},
+}
+
+// PricingCatalog は、モデルごとの料金表です。複数のゴルーチンから安全に使用できます。
+type PricingCatalog struct {
+	mu      sync.RWMutex
+	pricing map[Model]Pricing
+}
+
+// NewPricingCatalog は、既定の料金を登録した料金表を作成します。
+func NewPricingCatalog() *PricingCatalog {
+	return &PricingCatalog{pricing: maps.Clone(defaultPricing)}
+}
+
+// Set は、モデルの料金を登録します。既存の料金は上書きされます。
+func (c *PricingCatalog) Set(model Model, pricing Pricing) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pricing[model] = pricing
+}
+
+// Delete は、モデルの料金を削除します。
+func (c *PricingCatalog) Delete(model Model) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pricing, model)
+}
+
+// Lookup は、モデルの料金を返します。
+// 完全に一致するモデルがない場合は、日付やバージョンの付いたモデル名（例: claude-3-7-sonnet-20250219）として、
+// 最も長く一致する接頭辞のモデルの料金を返します。
+func (c *PricingCatalog) Lookup(model Model) (Pricing, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if p, ok := c.pricing[model]; ok {
+		return p, true
+	}
+
+	var found Pricing
+	longest := 0
+	name := string(model)
+	for key, p := range c.pricing {
+		prefix := string(key) + "-"
+		if len(prefix) <= longest || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if rest := name[len(prefix):]; rest != "" && rest[0] >= '0' && rest[0] <= '9' {
+			found = p
+			longest = len(prefix)
+		}
+	}
+	return found, longest > 0
+}
+
+// Cost は、候補のモデル名を順に検索し、最初に見つかった料金で使用量の料金を計算します。
+// 料金が登録されていない場合は nil を返します。
+func (c *PricingCatalog) Cost(usage Usage, candidates ...Model) *Cost {
+	for _, model := range candidates {
+		if model == "" {
+			continue
+		}
+		if p, ok := c.Lookup(model); ok {
+			cost := p.Cost(usage)
+			return &cost
+		}
+	}
+	return nil
+}
+
+// defaultCatalog は、Config.Pricing が指定されていない場合に使用する料金表です。
+var defaultCatalog = NewPricingCatalog()
+
+// DefaultPricingCatalog は、Config.Pricing が指定されていない場合に使用される共有の料金表を返します。
+// この料金表に Set した料金は、Config.Pricing を指定していないすべてのクライアントに反映されます。
+func DefaultPricingCatalog() *PricingCatalog {
+	return defaultCatalog
+}
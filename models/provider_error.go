@@ -0,0 +1,59 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ProviderError は、プロバイダのAPIから返されたエラーの詳細を表す構造体です。
+// errors.Is で ErrAPIRequest および Category（ErrRateLimited など）と一致し、
+// errors.As で元のSDKのエラー型も取得できます。
+type ProviderError struct {
+	// Provider は、エラーを返したプロバイダです。
+	Provider Provider
+	// StatusCode は、HTTPステータスコードです。
+	StatusCode int
+	// Type は、プロバイダ固有のエラーの種類です（例: rate_limit_error, RESOURCE_EXHAUSTED）。
+	Type string
+	// Code は、プロバイダ固有のエラーコードです（例: context_length_exceeded）。
+	Code string
+	// Message は、プロバイダが返したエラーメッセージです。
+	Message string
+	// Category は、エラーの分類です（ErrRateLimited など）。分類できない場合は nil です。
+	Category error
+	// Retryable は、再試行によって成功する可能性があるかどうかです。
+	Retryable bool
+	// RetryAfter は、プロバイダが指定した再試行までの待機時間です。指定がない場合は0です。
+	RetryAfter time.Duration
+	// RequestID は、プロバイダが発行したリクエストIDです。
+	RequestID string
+	// Err は、SDKから返された元のエラーです。
+	Err error
+}
+
+// Error は、エラーメッセージを返します。
+func (e *ProviderError) Error() string {
+	detail := e.Code
+	if detail == "" {
+		detail = e.Type
+	}
+	message := fmt.Sprintf("%s: %s: status %d", ErrAPIRequest, e.Provider, e.StatusCode)
+	if detail != "" {
+		message += " " + detail
+	}
+	if e.Message != "" {
+		message += ": " + e.Message
+	}
+	return message
+}
+
+// Is は、target が ErrAPIRequest またはエラーの分類と一致するかどうかを返します。
+func (e *ProviderError) Is(target error) bool {
+	return target == ErrAPIRequest || (e.Category != nil && errors.Is(e.Category, target))
+}
+
+// Unwrap は、SDKから返された元のエラーを返します。
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
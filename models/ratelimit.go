@@ -0,0 +1,10 @@
+package models
+
+// RateLimit は、クライアント側で行うレート制限の設定を表す構造体です。
+type RateLimit struct {
+	// RequestsPerMinute は、1分あたりのリクエスト数の上限です。0の場合は制限しません。
+	RequestsPerMinute int `json:"requests_per_minute,omitempty"`
+	// TokensPerMinute は、1分あたりのトークン数の上限です。0の場合は制限しません。
+	// リクエスト前に見積もったトークン数で待機し、レスポンスの実際の使用量で差分を調整します。
+	TokensPerMinute int `json:"tokens_per_minute,omitempty"`
+}
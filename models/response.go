@@ -0,0 +1,95 @@
+package models
+
+import "time"
+
+// FinishReason は、生成が終了した理由を表す型です。
+// 各プロバイダ固有の値は、以下の共通の値に正規化されます。
+type FinishReason string
+
+const (
+	// FinishReasonStop は、モデルが自然に生成を終えたか、停止シーケンスに達したことを表します。
+	FinishReasonStop FinishReason = "stop"
+	// FinishReasonLength は、最大トークン数に達したため生成が打ち切られたことを表します。
+	FinishReasonLength FinishReason = "length"
+	// FinishReasonSafety は、安全性フィルタやコンテンツフィルタにより生成が停止されたことを表します。
+	FinishReasonSafety FinishReason = "safety"
+	// FinishReasonToolUse は、モデルがツールの呼び出しを要求したことを表します。
+	FinishReasonToolUse FinishReason = "tool_use"
+	// FinishReasonOther は、上記以外の理由で生成が終了したことを表します。
+	FinishReasonOther FinishReason = "other"
+)
+
+// Usage は、トークン使用量を表す構造体です。
+type Usage struct {
+	// InputTokens は、入力（プロンプト）に使用されたトークン数です。
+	InputTokens int `json:"input_tokens"`
+	// OutputTokens は、出力（生成テキスト）に使用されたトークン数です。推論トークン（ReasoningTokens）を含みます。
+	OutputTokens int `json:"output_tokens"`
+	// CachedInputTokens は、入力のうちキャッシュから読み込まれたトークン数です。
+	CachedInputTokens int `json:"cached_input_tokens"`
+	// CacheCreationTokens は、入力のうちキャッシュへの書き込みに使用されたトークン数です（Anthropicのみ）。
+	CacheCreationTokens int `json:"cache_creation_tokens"`
+	// ReasoningTokens は、出力のうち推論（思考）に使用されたトークン数です。
+	ReasoningTokens int `json:"reasoning_tokens"`
+	// TotalTokens は、使用されたトークン数の合計です。
+	TotalTokens int `json:"total_tokens"`
+}
+
+// Add は、別のトークン使用量を加算します。
+func (u *Usage) Add(other Usage) {
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.CachedInputTokens += other.CachedInputTokens
+	u.CacheCreationTokens += other.CacheCreationTokens
+	u.ReasoningTokens += other.ReasoningTokens
+	u.TotalTokens += other.TotalTokens
+}
+
+// GenTextResponse は、テキスト生成の結果を表す構造体です。
+type GenTextResponse struct {
+	// Text は、生成されたテキストです。
+	Text string `json:"text"`
+	// ToolCalls は、モデルが要求したツール呼び出しです。
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// Tokens は、使用されたトークン数の合計です。Usage.TotalTokens と同じ値です。
+	Tokens int `json:"tokens"`
+	// Usage は、トークン使用量の内訳です。
+	Usage Usage `json:"usage"`
+	// FinishReason は、正規化された生成の終了理由です。
+	FinishReason FinishReason `json:"finish_reason"`
+	// Provider は、リクエストを処理したプロバイダです。
+	Provider Provider `json:"provider"`
+	// Model は、プロバイダから返された実際のモデルIDです。
+	Model Model `json:"model"`
+	// Latency は、リクエストの送信からレスポンスの受信までにかかった時間です。再試行した場合は、その待機時間も含みます。
+	Latency time.Duration `json:"latency"`
+	// RequestID は、プロバイダが発行したリクエストIDです。
+	RequestID string `json:"request_id,omitempty"`
+	// Attempts は、レスポンスを得るまでの試行回数です（再試行しなかった場合は1）。
+	Attempts int `json:"attempts"`
+	// FallbackFailures は、フォールバックによって応答したモデルより前に失敗したモデルの記録です。
+	FallbackFailures []FallbackFailure `json:"fallback_failures,omitempty"`
+	// Cost は、トークン使用量から計算した料金です。料金表にモデルが登録されていない場合は nil です。
+	Cost *Cost `json:"cost,omitempty"`
+}
+
+// FallbackFailure は、フォールバックチェーンの中で失敗したモデルの記録です。
+type FallbackFailure struct {
+	// Model は、失敗したモデルです。
+	Model Model `json:"model"`
+	// Provider は、失敗したモデルのプロバイダです。
+	Provider Provider `json:"provider"`
+	// Err は、モデルが返したエラーです。
+	Err error `json:"-"`
+	// Message は、エラーメッセージです。
+	Message string `json:"error"`
+}
+
+// Message は、レスポンスを会話履歴に追加するためのアシスタントメッセージに変換します。
+func (r *GenTextResponse) Message() Message {
+	return Message{
+		Role:      RoleAssistant,
+		Content:   r.Text,
+		ToolCalls: r.ToolCalls,
+	}
+}
@@ -0,0 +1,15 @@
+package models
+
+// StreamEvent は、ストリーミング生成で逐次返されるイベントを表す構造体です。
+type StreamEvent struct {
+	// Delta は、新たに生成されたテキストの差分です。
+	Delta string `json:"delta,omitempty"`
+	// Done は、ストリームの最終イベントであるかどうかを表します。
+	Done bool `json:"done"`
+	// FinishReason は、生成が終了した理由です。最終イベントでのみ設定されます。
+	FinishReason FinishReason `json:"finish_reason,omitempty"`
+	// Usage は、トークン使用量です。最終イベントでのみ設定されます。
+	Usage Usage `json:"usage"`
+	// Response は、生成結果全体を表すレスポンスです。最終イベントでのみ設定されます。
+	Response *GenTextResponse `json:"response,omitempty"`
+}
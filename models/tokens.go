@@ -0,0 +1,9 @@
+package models
+
+import "context"
+
+// TokenCounter は、リクエストの入力トークン数の計算をサポートするプロバイダのインターフェースです。
+type TokenCounter interface {
+	// CountTokens は、リクエストを送信せずに、入力（システムプロンプト、メッセージ、ツール定義など）のトークン数を返します。
+	CountTokens(ctx context.Context, params GenTextParams) (int, error)
+}
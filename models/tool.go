@@ -0,0 +1,49 @@
+package models
+
+import "encoding/json"
+
+// Tool は、モデルが呼び出すことのできるツール（関数）の定義を表す構造体です。
+// 1つの定義を、OpenAI、Anthropic、Geminiのすべてのモデルで利用できます。
+type Tool struct {
+	// Name は、ツールの名前です。
+	Name string `json:"name"`
+	// Description は、ツールの説明です。モデルがツールを選択する際の手がかりになります。
+	Description string `json:"description,omitempty"`
+	// Parameters は、ツールの引数を表すJSON Schemaです。
+	// ルートは "type": "object" である必要があります。
+	Parameters map[string]any `json:"parameters,omitempty"`
+}
+
+// ToolCall は、モデルから要求されたツール呼び出しを表す構造体です。
+type ToolCall struct {
+	// ID は、ツール呼び出しの識別子です。ツールの実行結果を返す際に使用します。
+	ID string `json:"id"`
+	// Name は、呼び出すツールの名前です。
+	Name string `json:"name"`
+	// Arguments は、JSON形式のツールの引数です。
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// ToolChoice は、モデルによるツールの選択方法を表す型です。
+// 定数以外の値を指定した場合は、その名前のツールの呼び出しを強制します。
+type ToolChoice string
+
+const (
+	// ToolChoiceAuto は、ツールを呼び出すかどうかをモデルに任せます。
+	ToolChoiceAuto ToolChoice = "auto"
+	// ToolChoiceNone は、ツールを呼び出さないようにします。
+	ToolChoiceNone ToolChoice = "none"
+	// ToolChoiceRequired は、いずれかのツールの呼び出しを強制します。
+	ToolChoiceRequired ToolChoice = "required"
+)
+
+// NewToolResultMessage は、ツールの実行結果をモデルに返すためのメッセージを作成します。
+// content には、実行結果をテキストまたはJSON文字列で指定します。
+func NewToolResultMessage(call ToolCall, content string) Message {
+	return Message{
+		Role:       RoleTool,
+		Content:    content,
+		ToolCallID: call.ID,
+		ToolName:   call.Name,
+	}
+}
@@ -0,0 +1,13 @@
+package models
+
+import "go.opentelemetry.io/otel/trace"
+
+// TracingConfig は、OpenTelemetry によるトレースの設定です。
+// 各プロバイダのクライアントは、テキスト生成ごとに OpenTelemetry の GenAI セマンティック規約に従ったスパンを作成します。
+type TracingConfig struct {
+	// TracerProvider は、スパンの作成に使用する TracerProvider です。nil の場合は otel.GetTracerProvider() を使用します。
+	TracerProvider trace.TracerProvider
+	// CaptureContent は、プロンプトと生成されたテキストをスパンのイベントとして記録するかどうかです。
+	// 個人情報や機密情報を含む可能性があるため、既定では記録しません。
+	CaptureContent bool
+}
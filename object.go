@@ -0,0 +1,88 @@
+package wrapper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+
+	"github.com/obutora/ai-wrapper/internal/jsonschema"
+)
+
+// genObjectMaxAttempts は、GenObject が出力の検証に失敗した場合に試行する最大回数です。
+const genObjectMaxAttempts = 3
+
+// schemaNamePattern は、スキーマ名に使用できない文字にマッチします。
+var schemaNamePattern = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// GenObject は、型 T からJSON Schemaを生成し、各プロバイダの構造化出力機能を使用して T の値を生成します。
+//
+// T には構造体を指定してください。フィールドは json タグの名前で出力され、
+// description タグを指定するとスキーマの説明としてモデルに渡されます。
+// T が構造体でない場合や、マップ、インターフェース、再帰的な型を含む場合は、リクエストを送信せずに ErrUnsupportedSchemaType を返します。
+// 出力がスキーマに適合しない場合は、検証エラーをモデルにフィードバックして再試行し、
+// 最終的に適合しなかった場合は ErrSchemaValidation を返します。
+func GenObject[T any](ctx context.Context, client LLMWrapper, params GenTextParams) (T, *GenTextResponse, error) {
+	var result T
+
+	t := reflect.TypeFor[T]()
+	schema, err := jsonschema.Generate(t)
+	if err != nil {
+		return result, nil, fmt.Errorf("%w: %v", ErrUnsupportedSchemaType, err)
+	}
+	params.ResponseFormat = &ResponseFormat{
+		Name:   schemaName(t),
+		Schema: schema,
+		Strict: true,
+	}
+
+	// プロンプトのみが指定されている場合は、再試行時に履歴を追加できるようメッセージに変換します
+	messages := slices.Clone(params.Messages)
+	if len(messages) == 0 && params.Prompt != "" {
+		messages = []Message{{Role: RoleUser, Content: params.Prompt}}
+		params.Prompt = ""
+	}
+
+	var res *GenTextResponse
+	var validationErr error
+	for range genObjectMaxAttempts {
+		params.Messages = messages
+
+		var err error
+		res, err = client.Generate(ctx, params)
+		if err != nil {
+			return result, nil, err
+		}
+
+		validationErr = jsonschema.Validate(schema, []byte(res.Text))
+		if validationErr == nil {
+			validationErr = json.Unmarshal([]byte(res.Text), &result)
+		}
+		if validationErr == nil {
+			return result, res, nil
+		}
+
+		// 検証エラーをモデルにフィードバックして再試行します
+		messages = append(messages,
+			Message{Role: RoleAssistant, Content: res.Text},
+			Message{
+				Role: RoleUser,
+				Content: fmt.Sprintf("The previous response did not conform to the JSON schema: %v\n"+
+					"Respond again with only JSON that conforms to the schema.", validationErr),
+			},
+		)
+	}
+
+	return result, res, fmt.Errorf("%w: %v", ErrSchemaValidation, validationErr)
+}
+
+// schemaName は、型名からスキーマ名を作成します。
+func schemaName(t reflect.Type) string {
+	name := schemaNamePattern.ReplaceAllString(t.Name(), "_")
+	if name == "" {
+		return "response"
+	}
+	return name
+}
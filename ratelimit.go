@@ -0,0 +1,315 @@
+package wrapper
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"math"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/obutora/ai-wrapper/internal/providers"
+)
+
+// tokenBucket は、一定の速度で補充されるトークンバケットです。
+// 実際の使用量で調整した結果、残量が負になることがあります。その場合は補充されるまで待機します。
+type tokenBucket struct {
+	mu       sync.Mutex
+	capacity float64
+	tokens   float64
+	rate     float64 // 1秒あたりの補充量
+	last     time.Time
+}
+
+// newTokenBucket は、1分あたり perMinute 個まで補充されるバケットを作成します。0以下の場合は nil を返します。
+func newTokenBucket(perMinute int) *tokenBucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &tokenBucket{
+		capacity: float64(perMinute),
+		tokens:   float64(perMinute),
+		rate:     float64(perMinute) / 60,
+		last:     time.Now(),
+	}
+}
+
+// refill は、前回からの経過時間に応じてトークンを補充します。呼び出し側でロックを取得している必要があります。
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// wait は、n 個のトークンを取得できるまで待機します。
+// n がバケットの容量を超える場合は、バケットが満杯になった時点で取得します。
+func (b *tokenBucket) wait(ctx context.Context, n float64) error {
+	if b == nil {
+		return nil
+	}
+
+	for {
+		b.mu.Lock()
+		b.refill(time.Now())
+		need := math.Min(n, b.capacity)
+		if b.tokens >= need {
+			b.tokens -= n
+			b.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((need - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w: %w", ErrRequestCanceled, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// adjust は、トークンの残量を増減します。見積もりと実際の使用量の差分の調整に使用します。
+func (b *tokenBucket) adjust(delta float64) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(time.Now())
+	b.tokens = math.Min(b.capacity, b.tokens+delta)
+}
+
+// rateLimitBuckets は、1つのプロバイダーまたはモデルに対するリクエスト数とトークン数のバケットです。
+type rateLimitBuckets struct {
+	requests *tokenBucket
+	tokens   *tokenBucket
+}
+
+func newRateLimitBuckets(limit RateLimit) *rateLimitBuckets {
+	return &rateLimitBuckets{
+		requests: newTokenBucket(limit.RequestsPerMinute),
+		tokens:   newTokenBucket(limit.TokensPerMinute),
+	}
+}
+
+// rateLimiter は、UnifiedClient のプロバイダーごと・モデルごとのレート制限を管理します。
+type rateLimiter struct {
+	mu        sync.Mutex
+	providers map[Provider]*rateLimitBuckets
+	models    map[Model]*rateLimitBuckets
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		providers: make(map[Provider]*rateLimitBuckets),
+		models:    make(map[Model]*rateLimitBuckets),
+	}
+}
+
+// buckets は、リクエストに適用されるバケットを返します。
+func (l *rateLimiter) buckets(provider Provider, model Model) []*rateLimitBuckets {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	buckets := []*rateLimitBuckets{}
+	if b, ok := l.providers[provider]; ok {
+		buckets = append(buckets, b)
+	}
+	if b, ok := l.models[model]; ok {
+		buckets = append(buckets, b)
+	}
+	return buckets
+}
+
+// acquire は、リクエスト1件と見積もったトークン数を取得できるまで待機します。
+// 戻り値の関数に実際のトークン使用量を渡すと、見積もりとの差分を調整します。
+// 使用量が不明な場合（エラーなど）は負の値を渡すと、見積もったトークンを返却します。
+func (l *rateLimiter) acquire(ctx context.Context, provider Provider, model Model, estimated int) (func(actual int), error) {
+	buckets := l.buckets(provider, model)
+	for i, b := range buckets {
+		if err := b.requests.wait(ctx, 1); err != nil {
+			refund(buckets[:i], estimated)
+			return nil, err
+		}
+		if err := b.tokens.wait(ctx, float64(estimated)); err != nil {
+			b.requests.adjust(1)
+			refund(buckets[:i], estimated)
+			return nil, err
+		}
+	}
+
+	return func(actual int) {
+		if actual < 0 {
+			actual = 0
+		}
+		for _, b := range buckets {
+			b.tokens.adjust(float64(estimated - actual))
+		}
+	}, nil
+}
+
+// limitedCall は、1回の呼び出しの中で送信するHTTPリクエスト（再試行やキーの切り替えを含む）ごとに、レート制限の枠を取得します。
+type limitedCall struct {
+	limiter   *rateLimiter
+	provider  Provider
+	model     Model
+	estimated int
+	attempts  int
+	release   func(actual int)
+}
+
+// start は、最初の試行の枠を取得できるまで待機し、呼び出しを開始します。
+// 2回目以降の試行の枠は、各試行の前に beforeAttempt で取得します。
+func (l *rateLimiter) start(ctx context.Context, provider Provider, model Model, estimated int) (*limitedCall, error) {
+	release, err := l.acquire(ctx, provider, model, estimated)
+	if err != nil {
+		return nil, err
+	}
+	return &limitedCall{limiter: l, provider: provider, model: model, estimated: estimated, release: release}, nil
+}
+
+// context は、各試行の前に beforeAttempt を呼び出すコンテキストを返します。
+func (c *limitedCall) context(ctx context.Context) context.Context {
+	return providers.WithAttemptHook(ctx, c.beforeAttempt)
+}
+
+// beforeAttempt は、2回目以降の試行の前に、新しいリクエスト1件と見積もったトークン数を取得できるまで待機します。
+// 失敗した前の試行はトークンを消費しなかったものとして見積もりを返却し、送信したリクエストの枠はそのまま消費します。
+func (c *limitedCall) beforeAttempt(ctx context.Context) error {
+	c.attempts++
+	if c.attempts == 1 {
+		return nil
+	}
+
+	c.release(-1)
+	release, err := c.limiter.acquire(ctx, c.provider, c.model, c.estimated)
+	if err != nil {
+		c.release = func(int) {}
+		return err
+	}
+	c.release = release
+	return nil
+}
+
+// done は、最後の試行の実際のトークン使用量で見積もりとの差分を調整します。使用量が不明な場合は負の値を渡します。
+func (c *limitedCall) done(actual int) {
+	c.release(actual)
+}
+
+// refund は、待機中にキャンセルされた場合に取得済みのリクエストとトークンを返却します。
+func refund(buckets []*rateLimitBuckets, estimated int) {
+	for _, b := range buckets {
+		b.requests.adjust(1)
+		b.tokens.adjust(float64(estimated))
+	}
+}
+
+// SetRateLimit は、プロバイダーに対するクライアント側のレート制限を設定します。
+// ゼロ値を指定すると、制限を解除します。
+func (c *UnifiedClient) SetRateLimit(provider Provider, limit RateLimit) {
+	c.limiter.mu.Lock()
+	defer c.limiter.mu.Unlock()
+
+	if limit == (RateLimit{}) {
+		delete(c.limiter.providers, provider)
+		return
+	}
+	c.limiter.providers[provider] = newRateLimitBuckets(limit)
+}
+
+// SetModelRateLimit は、モデルに対するクライアント側のレート制限を設定します。
+// プロバイダーの制限も設定されている場合は、両方の制限を満たすまで待機します。
+func (c *UnifiedClient) SetModelRateLimit(model Model, limit RateLimit) {
+	c.limiter.mu.Lock()
+	defer c.limiter.mu.Unlock()
+
+	if limit == (RateLimit{}) {
+		delete(c.limiter.models, model)
+		return
+	}
+	c.limiter.models[model] = newRateLimitBuckets(limit)
+}
+
+// generate は、会話履歴をモデルに合わせて調整し、レート制限に従ってテキストを生成します。
+func (c *UnifiedClient) generate(ctx context.Context, client LLMWrapper, params GenTextParams) (*GenTextResponse, error) {
+	params, err := c.fitHistory(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	call, err := c.limiter.start(ctx, c.getProviderForModel(params.Model), params.Model, estimateTokens(params, c.maxToken))
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := client.Generate(call.context(ctx), params)
+	if err != nil {
+		call.done(-1)
+		return nil, err
+	}
+	call.done(res.Usage.TotalTokens)
+	return res, nil
+}
+
+// stream は、会話履歴をモデルに合わせて調整し、レート制限に従ってテキストを逐次生成します。
+func (c *UnifiedClient) stream(ctx context.Context, client LLMWrapper, params GenTextParams) iter.Seq2[StreamEvent, error] {
+	return func(yield func(StreamEvent, error) bool) {
+		params, err := c.fitHistory(ctx, params)
+		if err != nil {
+			yield(StreamEvent{}, err)
+			return
+		}
+
+		estimated := estimateTokens(params, c.maxToken)
+		call, err := c.limiter.start(ctx, c.getProviderForModel(params.Model), params.Model, estimated)
+		if err != nil {
+			yield(StreamEvent{}, err)
+			return
+		}
+
+		// 最後のイベントを受け取らずに終了した場合は、見積もりをそのまま使用量とみなします
+		actual := estimated
+		defer func() { call.done(actual) }()
+		for event, err := range client.GenTextStream(call.context(ctx), params) {
+			if err != nil {
+				actual = -1
+			} else if event.Response != nil {
+				actual = event.Response.Usage.TotalTokens
+			}
+			if !yield(event, err) {
+				return
+			}
+		}
+	}
+}
+
+// estimateTokens は、リクエストで使用するトークン数を見積もります。
+// 入力は4文字を1トークンとして概算し、出力の上限として MaxTokens（未指定の場合は maxToken）を加算します。
+func estimateTokens(params GenTextParams, maxToken int) int {
+	chars := utf8.RuneCountInString(params.SystemPrompt) + utf8.RuneCountInString(params.Prompt)
+	for _, msg := range params.Messages {
+		chars += utf8.RuneCountInString(msg.Text())
+		for _, call := range msg.ToolCalls {
+			chars += utf8.RuneCount(call.Arguments)
+		}
+	}
+	for _, tool := range params.Tools {
+		schema, _ := json.Marshal(tool.Parameters)
+		chars += utf8.RuneCountInString(tool.Name) + utf8.RuneCountInString(tool.Description) + len(schema)
+	}
+	return chars/4 + 1 + cmp.Or(params.MaxTokens, maxToken)
+}
+
+// estimateEmbedTokens は、埋め込みベクトルの生成で使用するトークン数を見積もります。
+func estimateEmbedTokens(params EmbedParams) int {
+	chars := 0
+	for _, input := range params.Inputs {
+		chars += utf8.RuneCountInString(input)
+	}
+	return chars/4 + 1
+}
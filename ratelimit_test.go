@@ -0,0 +1,277 @@
+package wrapper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/obutora/ai-wrapper/internal/providers"
+	"github.com/obutora/ai-wrapper/models"
+)
+
+func TestTokenBucketRefill(t *testing.T) {
+	tests := []struct {
+		name    string
+		tokens  float64
+		elapsed time.Duration
+		want    float64
+	}{
+		{name: "refills at the per-minute rate", tokens: 0, elapsed: 2 * time.Second, want: 2},
+		{name: "refills from a negative balance", tokens: -5, elapsed: 10 * time.Second, want: 5},
+		{name: "caps at capacity", tokens: 30, elapsed: time.Hour, want: 60},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTokenBucket(60)
+			now := time.Now()
+			b.tokens = tt.tokens
+			b.last = now.Add(-tt.elapsed)
+			b.refill(now)
+			if b.tokens != tt.want {
+				t.Errorf("tokens = %v, want %v", b.tokens, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenBucketWait(t *testing.T) {
+	// 1秒あたり10個補充されるため、空のバケットから1個取得するには約100ミリ秒かかります
+	b := newTokenBucket(600)
+	if err := b.wait(context.Background(), 600); err != nil {
+		t.Fatalf("wait() error = %v", err)
+	}
+
+	start := time.Now()
+	if err := b.wait(context.Background(), 1); err != nil {
+		t.Fatalf("wait() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("wait() returned after %v, want about 100ms", elapsed)
+	}
+}
+
+func TestTokenBucketWaitOverCapacity(t *testing.T) {
+	// 容量を超える要求は、満杯のバケットから取得します
+	b := newTokenBucket(10)
+	if err := b.wait(context.Background(), 100); err != nil {
+		t.Fatalf("wait() error = %v", err)
+	}
+	if b.tokens != -90 {
+		t.Errorf("tokens = %v, want -90", b.tokens)
+	}
+}
+
+func TestTokenBucketWaitCanceled(t *testing.T) {
+	b := newTokenBucket(1)
+	b.tokens = 0
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := b.wait(ctx, 1)
+	if !errors.Is(err, ErrRequestCanceled) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wait() error = %v, want ErrRequestCanceled and context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("wait() returned after %v, want it to stop at the deadline", elapsed)
+	}
+}
+
+func TestRateLimiterRefundsOnCancel(t *testing.T) {
+	limiter := newRateLimiter()
+	limiter.providers[ProviderOpenAI] = newRateLimitBuckets(RateLimit{RequestsPerMinute: 10, TokensPerMinute: 100})
+	buckets := limiter.providers[ProviderOpenAI]
+	buckets.tokens.tokens = 0
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := limiter.acquire(ctx, ProviderOpenAI, models.ModelGPT4o, 50); !errors.Is(err, ErrRequestCanceled) {
+		t.Fatalf("acquire() error = %v, want ErrRequestCanceled", err)
+	}
+	if got := buckets.requests.tokens; got < 10 {
+		t.Errorf("requests = %v, want the request refunded (10)", got)
+	}
+}
+
+func TestLimitedCallPerAttempt(t *testing.T) {
+	limiter := newRateLimiter()
+	limiter.providers[ProviderOpenAI] = newRateLimitBuckets(RateLimit{RequestsPerMinute: 2, TokensPerMinute: 1000})
+	buckets := limiter.providers[ProviderOpenAI]
+
+	call, err := limiter.start(context.Background(), ProviderOpenAI, models.ModelGPT4o, 100)
+	if err != nil {
+		t.Fatalf("start() error = %v", err)
+	}
+	// 最初の試行は start で取得した枠を使用します
+	if err := call.beforeAttempt(context.Background()); err != nil {
+		t.Fatalf("beforeAttempt(1) error = %v", err)
+	}
+	if err := call.beforeAttempt(context.Background()); err != nil {
+		t.Fatalf("beforeAttempt(2) error = %v", err)
+	}
+	if got := buckets.requests.tokens; got >= 1 {
+		t.Errorf("requests = %v after two attempts, want both used", got)
+	}
+	// 失敗した試行の見積もりトークンは返却されます
+	if got := buckets.tokens.tokens; got < 900 || got >= 901 {
+		t.Errorf("tokens = %v, want only the current attempt's 100 held", got)
+	}
+
+	// 3回目の試行は、リクエストの枠が補充されるまで待機します
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := call.beforeAttempt(ctx); !errors.Is(err, ErrRequestCanceled) {
+		t.Fatalf("beforeAttempt(3) error = %v, want ErrRequestCanceled", err)
+	}
+	call.done(-1)
+	if got := buckets.tokens.tokens; got < 1000 {
+		t.Errorf("tokens = %v after the call failed, want all tokens returned", got)
+	}
+}
+
+// redirectTransport は、すべてのリクエストをテスト用のサーバーに転送します。
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (t redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// newLimitedOpenAIClient は、handler に転送するOpenAIクライアントと、OpenAIのリクエストを1分あたり10件に制限する UnifiedClient を作成します。
+// 最初に503を返す回数を failures で指定します。
+func newLimitedOpenAIClient(t *testing.T, config models.Config, failures int32, handler http.HandlerFunc) (*UnifiedClient, *rateLimitBuckets, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	// SDKは http.DefaultClient を使用するため、テストの間だけ転送先を差し替えます
+	target, _ := url.Parse(server.URL)
+	transport := http.DefaultClient.Transport
+	http.DefaultClient.Transport = redirectTransport{target: target}
+	t.Cleanup(func() { http.DefaultClient.Transport = transport })
+
+	client := &UnifiedClient{
+		clients:              map[Provider]LLMWrapper{ProviderOpenAI: providers.NewOpenAIClient("test-key", config)},
+		limiter:              newRateLimiter(),
+		customModelProviders: make(map[Model]Provider),
+		fallbacks:            make(map[Model][]Model),
+	}
+	client.SetRateLimit(ProviderOpenAI, RateLimit{RequestsPerMinute: 10, TokensPerMinute: 100000})
+	return client, client.limiter.providers[ProviderOpenAI], &requests
+}
+
+func TestEmbedLimitsEachAttempt(t *testing.T) {
+	client, buckets, requests := newLimitedOpenAIClient(t, models.Config{Retry: models.RetryPolicy{MaxAttempts: 3, BaseBackoff: time.Millisecond}}, 1, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Input []string `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		data := make([]string, len(body.Input))
+		for i := range data {
+			data[i] = fmt.Sprintf(`{"object":"embedding","index":%d,"embedding":[0.1]}`, i)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"object":"list","model":"text-embedding-3-small","data":[%s],"usage":{"prompt_tokens":%d,"total_tokens":%d}}`,
+			strings.Join(data, ","), len(body.Input), len(body.Input))
+	})
+
+	// 入力の数が上限を超えるため、2つのバッチに分割されます
+	inputs := make([]string, 2049)
+	for i := range inputs {
+		inputs[i] = "a"
+	}
+	start := time.Now()
+	res, err := client.Embed(context.Background(), EmbedParams{Model: models.ModelTextEmbedding3Small, Inputs: inputs})
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if len(res.Embeddings) != len(inputs) {
+		t.Fatalf("len(Embeddings) = %d, want %d", len(res.Embeddings), len(inputs))
+	}
+
+	// 最初のバッチの再試行を含めた3件のリクエストが、それぞれ枠を使用します
+	if got := requests.Load(); got != 3 {
+		t.Fatalf("requests = %d, want 3", got)
+	}
+	if got := buckets.requests.tokens; got >= 8 {
+		t.Errorf("request slots = %v, want 3 of 10 used", got)
+	}
+	// 最後に実際の使用量で調整されるため、消費したトークン数は使用量と一致します（呼び出し中の補充分を除く）
+	refilled := time.Since(start).Minutes() * 100000
+	if got := buckets.tokens.tokens; got < 100000-2049 || got > 100000-2049+refilled {
+		t.Errorf("tokens = %v, want the 2049 used tokens consumed", got)
+	}
+}
+
+func TestStreamLimitsEachAttempt(t *testing.T) {
+	// Retry が未設定の場合も、SDKではなく既定のポリシーで再試行するため、各試行が制限されます
+	client, buckets, requests := newLimitedOpenAIClient(t, models.Config{}, 1, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(`data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4o-2024-08-06","choices":[{"index":0,"delta":{"role":"assistant","content":"Paris"},"finish_reason":"stop"}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4o-2024-08-06","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}
+
+data: [DONE]
+
+`))
+	})
+
+	var text string
+	for event, err := range client.GenTextStream(context.Background(), GenTextParams{Model: models.ModelGPT4o, Prompt: "Capital of France?"}) {
+		if err != nil {
+			t.Fatalf("GenTextStream() error = %v", err)
+		}
+		text += event.Delta
+	}
+	if text != "Paris" {
+		t.Errorf("text = %q, want %q", text, "Paris")
+	}
+
+	// 再試行を含めた2件のリクエストが、それぞれ枠を使用します
+	if got := requests.Load(); got != 2 {
+		t.Fatalf("requests = %d, want 2", got)
+	}
+	if got := buckets.requests.tokens; got >= 9 {
+		t.Errorf("request slots = %v, want 2 of 10 used", got)
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   GenTextParams
+		maxToken int
+		want     int
+	}{
+		{name: "input only", params: GenTextParams{Prompt: "12345678"}, want: 3},
+		{name: "adds MaxTokens", params: GenTextParams{Prompt: "12345678", MaxTokens: 100}, maxToken: 500, want: 103},
+		{name: "falls back to Config.MaxToken", params: GenTextParams{Prompt: "12345678"}, maxToken: 500, want: 503},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := estimateTokens(tt.params, tt.maxToken); got != tt.want {
+				t.Errorf("estimateTokens() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
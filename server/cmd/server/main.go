@@ -16,9 +16,13 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-sql-driver/mysql"
 	"github.com/joho/godotenv"
+	wrapper "github.com/obutora/ai-wrapper"
 	"github.com/obutora/go_graphql_template/generated/ent"
 	"github.com/obutora/go_graphql_template/graph/generated"
 	"github.com/obutora/go_graphql_template/graph/resolver"
+	"github.com/obutora/go_graphql_template/infra"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	slogchi "github.com/samber/slog-chi"
 )
 
@@ -72,8 +76,17 @@ func main() {
 		log.Fatalf("failed creating schema resources: %v", err)
 	}
 
+	// LLM の呼び出しのメトリクスを /metrics で公開するため、既定の Registry に登録する
+	metrics := wrapper.NewMetrics(wrapper.MetricsConfig{})
+	prometheus.MustRegister(metrics)
+	llm, err := infra.NewLLMClient(metrics)
+	if err != nil {
+		log.Fatalf("failed creating LLM client: %v", err)
+	}
+
 	srv := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: &resolver.Resolver{
 		Client: client,
+		LLM:    llm,
 	}}))
 
 	// authClient, err := initFirebase(ctx)
@@ -85,6 +98,16 @@ func main() {
 	//   - Logs to stdout.
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 
+	r := newRouter(logger, srv, promhttp.Handler())
+
+	fmt.Println("connect to http://localhost:8080/ for GraphQL playground")
+
+	// NOTE: localhostを付けないとWindows環境で8080portがFirewallを通らない
+	log.Fatal(http.ListenAndServe(":"+"8080", r))
+}
+
+// newRouter は GraphQL のエンドポイントと Prometheus のメトリクスのエンドポイントを持つルーターを作成します
+func newRouter(logger *slog.Logger, srv http.Handler, metrics http.Handler) *chi.Mux {
 	r := chi.NewRouter()
 	// Middleware
 	r.Use(slogchi.New(logger))
@@ -92,11 +115,8 @@ func main() {
 	// r.Use(FirebaseAuthMiddleware(authClient))
 	r.Get("/", playground.Handler("GraphQL playground", "/query"))
 	r.Handle("/query", srv)
-
-	fmt.Println("connect to http://localhost:8080/ for GraphQL playground")
-
-	// NOTE: localhostを付けないとWindows環境で8080portがFirewallを通らない
-	log.Fatal(http.ListenAndServe(":"+"8080", r))
+	r.Handle("/metrics", metrics)
+	return r
 }
 
 // // FirebaseAuthMiddleware Firebase認証ミドルウェア
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	wrapper "github.com/obutora/ai-wrapper"
+	"github.com/obutora/ai-wrapper/models"
+	"github.com/obutora/go_graphql_template/infra"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	t.Setenv("GEMINI_API_KEY", "")
+
+	registry := prometheus.NewRegistry()
+	metrics := wrapper.NewMetrics(wrapper.MetricsConfig{})
+	registry.MustRegister(metrics)
+
+	llm, err := infra.NewLLMClient(metrics)
+	if err != nil {
+		t.Fatalf("NewLLMClient() error = %v", err)
+	}
+	// メッセージが空のリクエストはAPIを呼び出す前に失敗するが、呼び出しとして記録される
+	if _, err := llm.Generate(context.Background(), models.GenTextParams{Model: models.ModelGPT4o}); err == nil {
+		t.Fatal("Generate() error = nil, want validation error")
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	server := httptest.NewServer(newRouter(logger, http.NotFoundHandler(), promhttp.HandlerFor(registry, promhttp.HandlerOpts{})))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics error = %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /metrics status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read body error = %v", err)
+	}
+
+	want := `ai_wrapper_requests_total{model="gpt-4o",provider="openai",status="_OTHER",stream="false"} 1`
+	if !strings.Contains(string(body), want) {
+		t.Errorf("GET /metrics body does not contain %q:\n%s", want, body)
+	}
+}
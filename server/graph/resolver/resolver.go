@@ -1,6 +1,9 @@
 package resolver
 
-import "github.com/obutora/go_graphql_template/generated/ent"
+import (
+	wrapper "github.com/obutora/ai-wrapper"
+	"github.com/obutora/go_graphql_template/generated/ent"
+)
 
 // This file will not be regenerated automatically.
 //
@@ -8,4 +11,5 @@ import "github.com/obutora/go_graphql_template/generated/ent"
 
 type Resolver struct {
 	Client *ent.Client
+	LLM    *wrapper.UnifiedClient
 }
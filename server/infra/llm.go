@@ -0,0 +1,32 @@
+package infra
+
+import (
+	"os"
+
+	wrapper "github.com/obutora/ai-wrapper"
+	"github.com/obutora/ai-wrapper/models"
+)
+
+// llmAPIKeyEnvs は、各プロバイダのAPIキーを読み込む環境変数です
+var llmAPIKeyEnvs = map[models.Provider]string{
+	models.ProviderOpenAI:    "OPENAI_API_KEY",
+	models.ProviderAnthropic: "ANTHROPIC_API_KEY",
+	models.ProviderGemini:    "GEMINI_API_KEY",
+}
+
+// NewLLMClient は環境変数に設定されたAPIキーを使用して、複数のプロバイダを呼び出せるクライアントを作成します
+// 呼び出しごとの計測結果は metrics に記録されます
+func NewLLMClient(metrics wrapper.MetricsRecorder) (*wrapper.UnifiedClient, error) {
+	apiKeys := make(map[models.Provider]string)
+	for provider, env := range llmAPIKeyEnvs {
+		if apiKey := os.Getenv(env); apiKey != "" {
+			apiKeys[provider] = apiKey
+		}
+	}
+
+	return wrapper.NewUnifiedClient(apiKeys, models.Config{
+		MaxToken: 4096,
+		Retry:    models.DefaultRetryPolicy(),
+		Metrics:  metrics,
+	})
+}
@@ -1,7 +1,9 @@
 package wrapper
 
 import (
+	"context"
 	"fmt"
+	"iter"
 
 	"github.com/obutora/ai-wrapper/internal/providers"
 	"github.com/obutora/ai-wrapper/models"
@@ -28,49 +30,288 @@ const (
 	RoleUser      = models.RoleUser
 	RoleAssistant = models.RoleAssistant
 	RoleSystem    = models.RoleSystem
+	RoleTool      = models.RoleTool
 )
 
 // Message は、LLMとのやり取りに使用するメッセージを表す構造体です。
 type Message = models.Message
 
+// Part は、マルチパートメッセージの1つのパート（テキスト、画像、ドキュメント）を表す構造体です。
+type Part = models.Part
+
+// PartType は、パートの種類を表す型です。
+type PartType = models.PartType
+
+// パートの種類の定数
+const (
+	PartTypeText     = models.PartTypeText
+	PartTypeImage    = models.PartTypeImage
+	PartTypeDocument = models.PartTypeDocument
+)
+
+// TextPart は、テキストのパートを作成します。
+func TextPart(text string) Part {
+	return models.TextPart(text)
+}
+
+// ImagePart は、バイト列から画像のパートを作成します。
+func ImagePart(data []byte) Part {
+	return models.ImagePart(data)
+}
+
+// ImagePartFromBase64 は、base64文字列またはデータURLから画像のパートを作成します。
+func ImagePartFromBase64(encoded string) (Part, error) {
+	return models.ImagePartFromBase64(encoded)
+}
+
+// ImagePartFromURL は、URLから画像のパートを作成します。
+func ImagePartFromURL(url string) Part {
+	return models.ImagePartFromURL(url)
+}
+
+// DocumentPart は、バイト列からドキュメント（PDF）のパートを作成します。
+func DocumentPart(data []byte) Part {
+	return models.DocumentPart(data)
+}
+
+// DocumentPartFromURL は、URLからドキュメント（PDF）のパートを作成します。
+func DocumentPartFromURL(url string) Part {
+	return models.DocumentPartFromURL(url)
+}
+
+// Tool は、モデルが呼び出すことのできるツール（関数）の定義を表す構造体です。
+type Tool = models.Tool
+
+// ToolCall は、モデルから要求されたツール呼び出しを表す構造体です。
+type ToolCall = models.ToolCall
+
+// ToolChoice は、モデルによるツールの選択方法を表す型です。
+type ToolChoice = models.ToolChoice
+
+// ツール選択方法の定数
+const (
+	ToolChoiceAuto     = models.ToolChoiceAuto
+	ToolChoiceNone     = models.ToolChoiceNone
+	ToolChoiceRequired = models.ToolChoiceRequired
+)
+
+// NewToolResultMessage は、ツールの実行結果をモデルに返すためのメッセージを作成します。
+func NewToolResultMessage(call ToolCall, content string) Message {
+	return models.NewToolResultMessage(call, content)
+}
+
 // GenTextParams は、テキスト生成に必要なパラメータを表す構造体です。
 type GenTextParams = models.GenTextParams
 
+// ResponseFormat は、構造化出力のJSON Schemaを表す構造体です。
+type ResponseFormat = models.ResponseFormat
+
+// CacheBreakpoint は、プロンプトキャッシュのブレークポイントを表す構造体です。
+type CacheBreakpoint = models.CacheBreakpoint
+
+// CacheTarget は、プロンプトキャッシュのブレークポイントを置く対象を表す型です。
+type CacheTarget = models.CacheTarget
+
+// キャッシュのブレークポイントの対象の定数
+const (
+	CacheTargetSystem  = models.CacheTargetSystem
+	CacheTargetTools   = models.CacheTargetTools
+	CacheTargetMessage = models.CacheTargetMessage
+)
+
+// CacheSystemPrompt は、システムプロンプトにブレークポイントを置きます。
+func CacheSystemPrompt() CacheBreakpoint {
+	return models.CacheSystemPrompt()
+}
+
+// CacheTools は、ツール定義にブレークポイントを置きます。
+func CacheTools() CacheBreakpoint {
+	return models.CacheTools()
+}
+
+// CacheMessage は、指定したインデックスのメッセージにブレークポイントを置きます。
+func CacheMessage(index int) CacheBreakpoint {
+	return models.CacheMessage(index)
+}
+
+// CachedContentParams は、Geminiのコンテキストキャッシュの作成に必要なパラメータを表す構造体です。
+type CachedContentParams = models.CachedContentParams
+
+// CachedContent は、作成済みのコンテキストキャッシュを表す構造体です。
+type CachedContent = models.CachedContent
+
+// CacheManager は、コンテキストキャッシュの管理をサポートするプロバイダのインターフェースです。
+// Geminiのクライアントが実装しています。
+type CacheManager = models.CacheManager
+
 // GenTextResponse は、テキスト生成の結果を表す構造体です。
 type GenTextResponse = models.GenTextResponse
 
+// FinishReason は、生成が終了した理由を表す型です。
+type FinishReason = models.FinishReason
+
+// 正規化された終了理由の定数
+const (
+	FinishReasonStop    = models.FinishReasonStop
+	FinishReasonLength  = models.FinishReasonLength
+	FinishReasonSafety  = models.FinishReasonSafety
+	FinishReasonToolUse = models.FinishReasonToolUse
+	FinishReasonOther   = models.FinishReasonOther
+)
+
+// StreamEvent は、ストリーミング生成で逐次返されるイベントを表す構造体です。
+type StreamEvent = models.StreamEvent
+
+// Usage は、トークン使用量を表す構造体です。
+type Usage = models.Usage
+
 // LLMWrapper は、LLMプロバイダとのやり取りを抽象化するインターフェースです。
 type LLMWrapper = models.LLMWrapper
 
+// RetryPolicy は、一時的なエラーに対する再試行の設定を表す構造体です。
+type RetryPolicy = models.RetryPolicy
+
+// DefaultRetryPolicy は、推奨される再試行の設定を返します。
+func DefaultRetryPolicy() RetryPolicy {
+	return models.DefaultRetryPolicy()
+}
+
+// ModelInfo は、モデルの情報を表す構造体です。
+type ModelInfo = models.ModelInfo
+
+// Capabilities は、モデルが対応している機能を表す構造体です。
+type Capabilities = models.Capabilities
+
+// ModelRegistry は、モデルの情報を管理するレジストリです。
+type ModelRegistry = models.ModelRegistry
+
+// DefaultModelRegistry は、モデルの判定や検証に使用される共有のレジストリを返します。
+func DefaultModelRegistry() *ModelRegistry {
+	return models.DefaultModelRegistry()
+}
+
+// Pricing は、モデルの100万トークンあたりの料金（USD）を表す構造体です。
+type Pricing = models.Pricing
+
+// Cost は、トークン使用量から計算した料金（USD）の内訳を表す構造体です。
+type Cost = models.Cost
+
+// PricingCatalog は、モデルごとの料金表です。
+type PricingCatalog = models.PricingCatalog
+
+// NewPricingCatalog は、既定の料金を登録した料金表を作成します。
+func NewPricingCatalog() *PricingCatalog {
+	return models.NewPricingCatalog()
+}
+
+// DefaultPricingCatalog は、Config.Pricing が指定されていない場合に使用される共有の料金表を返します。
+func DefaultPricingCatalog() *PricingCatalog {
+	return models.DefaultPricingCatalog()
+}
+
+// CostTracker は、レスポンスの料金をプロバイダ・モデル・タグごとに集計します。
+type CostTracker = models.CostTracker
+
+// CostReport は、CostTracker が集計した料金の内訳を表す構造体です。
+type CostReport = models.CostReport
+
+// NewCostTracker は、新しい CostTracker を作成します。
+func NewCostTracker() *CostTracker {
+	return models.NewCostTracker()
+}
+
+// RateLimit は、クライアント側で行うレート制限の設定を表す構造体です。
+type RateLimit = models.RateLimit
+
+// KeySelection は、APIキーのプールからキーを選択する方法を表す型です。
+type KeySelection = models.KeySelection
+
+const (
+	KeySelectionRoundRobin           = models.KeySelectionRoundRobin
+	KeySelectionLeastRecentlyLimited = models.KeySelectionLeastRecentlyLimited
+)
+
+// KeyPoolConfig は、プロバイダごとのAPIキーのプールの設定を表す構造体です。
+type KeyPoolConfig = models.KeyPoolConfig
+
+// KeyStats は、APIキーごとの使用状況を表す構造体です。
+type KeyStats = models.KeyStats
+
+// EmbedParams は、埋め込みベクトルの生成に必要なパラメータを表す構造体です。
+type EmbedParams = models.EmbedParams
+
+// EmbedResponse は、埋め込みベクトルの生成結果を表す構造体です。
+type EmbedResponse = models.EmbedResponse
+
+// Embedder は、埋め込みベクトルの生成をサポートするプロバイダのインターフェースです。
+// OpenAIとGeminiのクライアントが実装しています。
+type Embedder = models.Embedder
+
+// TokenCounter は、入力トークン数の計算をサポートするプロバイダのインターフェースです。
+// すべての組み込みプロバイダのクライアントが実装しています。
+type TokenCounter = models.TokenCounter
+
 // エラー定数
 var (
-	ErrUnsupportedProvider = models.ErrUnsupportedProvider
-	ErrInvalidAPIKey       = models.ErrInvalidAPIKey
-	ErrInvalidModel        = models.ErrInvalidModel
-	ErrEmptyMessages       = models.ErrEmptyMessages
-	ErrAPIRequest          = models.ErrAPIRequest
+	ErrUnsupportedProvider         = models.ErrUnsupportedProvider
+	ErrInvalidAPIKey               = models.ErrInvalidAPIKey
+	ErrInvalidModel                = models.ErrInvalidModel
+	ErrEmptyMessages               = models.ErrEmptyMessages
+	ErrAPIRequest                  = models.ErrAPIRequest
+	ErrRequestCanceled             = models.ErrRequestCanceled
+	ErrSchemaValidation            = models.ErrSchemaValidation
+	ErrUnsupportedSchemaType       = models.ErrUnsupportedSchemaType
+	ErrVisionNotSupported          = models.ErrVisionNotSupported
+	ErrUnsupportedContent          = models.ErrUnsupportedContent
+	ErrEmptyInputs                 = models.ErrEmptyInputs
+	ErrEmbeddingNotSupported       = models.ErrEmbeddingNotSupported
+	ErrTokenCountingNotSupported   = models.ErrTokenCountingNotSupported
+	ErrUnsupportedParameter        = models.ErrUnsupportedParameter
+	ErrInvalidCacheBreakpoint      = models.ErrInvalidCacheBreakpoint
+	ErrCacheNotFound               = models.ErrCacheNotFound
+	ErrCacheManagementNotSupported = models.ErrCacheManagementNotSupported
+	ErrNoAvailableKey              = models.ErrNoAvailableKey
+	ErrRateLimited                 = models.ErrRateLimited
+	ErrAuthentication              = models.ErrAuthentication
+	ErrContextLengthExceeded       = models.ErrContextLengthExceeded
+	ErrContentFiltered             = models.ErrContentFiltered
+	ErrProviderUnavailable         = models.ErrProviderUnavailable
 )
 
+// ProviderError は、プロバイダのAPIから返されたエラーの詳細を表す構造体です。
+type ProviderError = models.ProviderError
+
+// FallbackFailure は、フォールバックチェーンの中で失敗したモデルの記録です。
+type FallbackFailure = models.FallbackFailure
+
+// Ptr は、値のポインタを返します。GenTextParams の省略可能なパラメータの指定に使用します。
+func Ptr[T any](v T) *T {
+	return &v
+}
+
 // NewClient は、指定されたプロバイダとAPIキーに基づいて新しいLLMWrapperクライアントを作成します。
 func NewClient(provider Provider, apiKey string, config models.Config) (LLMWrapper, error) {
 	if apiKey == "" {
 		return nil, ErrInvalidAPIKey
 	}
 
+	var client LLMWrapper
 	switch provider {
 	case ProviderOpenAI:
-		return providers.NewOpenAIClient(apiKey, config), nil
+		client = providers.NewOpenAIClient(apiKey, config)
 	case ProviderAnthropic:
-		return providers.NewAnthropicClient(apiKey, config), nil
+		client = providers.NewAnthropicClient(apiKey, config)
 	case ProviderGemini:
-		client := providers.NewGeminiClient(apiKey, config)
-		if client == nil {
+		geminiClient := providers.NewGeminiClient(apiKey, config)
+		if geminiClient == nil {
 			return nil, fmt.Errorf("failed to create Gemini client")
 		}
-		return client, nil
+		client = geminiClient
 	default:
 		return nil, fmt.Errorf("%w: %s", ErrUnsupportedProvider, provider)
 	}
+
+	return withMiddleware(client, config.Middleware), nil
 }
 
 // UnifiedClient は、複数のプロバイダーを統合したクライアントです。
@@ -78,25 +319,80 @@ func NewClient(provider Provider, apiKey string, config models.Config) (LLMWrapp
 type UnifiedClient struct {
 	clients              map[Provider]LLMWrapper
 	customModelProviders map[Model]Provider // カスタムモデル名とプロバイダーのマッピング
+	fallbacks            map[Model][]Model  // モデルごとのフォールバックチェーン
+	pools                map[Provider]*keyPool
+	limiter              *rateLimiter
+	costs                *CostTracker
+	maxToken             int // Config.MaxToken（会話履歴の調整とレート制限の見積もりで最大出力トークン数の既定値として使用）
+	tracing              models.TracingConfig
+	middleware           []Middleware
+	handler              Handler // Middleware を適用した Handler（Middleware がない場合は nil）
 }
 
 // NewUnifiedClient は、複数のプロバイダーを統合した新しいクライアントを作成します。
 // APIキーのマップを受け取り、各プロバイダーのクライアントを初期化します。
 func NewUnifiedClient(apiKeys map[Provider]string, config models.Config) (*UnifiedClient, error) {
+	keys := make(map[Provider][]string, len(apiKeys))
+	for provider, apiKey := range apiKeys {
+		keys[provider] = []string{apiKey}
+	}
+
+	return NewUnifiedClientWithKeys(keys, config)
+}
+
+// NewUnifiedClientWithKeys は、プロバイダーごとに複数のAPIキーを使用するクライアントを作成します。
+// キーは Config.KeyPool の設定に従って切り替えられ、レート制限や認証エラーを返したキーは一時的に除外されます。
+func NewUnifiedClientWithKeys(apiKeys map[Provider][]string, config models.Config) (*UnifiedClient, error) {
 	clients := make(map[Provider]LLMWrapper)
+	pools := make(map[Provider]*keyPool)
 
-	for provider, apiKey := range apiKeys {
-		client, err := NewClient(provider, apiKey, config)
+	// Middleware は UnifiedClient で一度だけ適用するため、各キーのクライアントには適用しません
+	poolConfig := config
+	poolConfig.Middleware = nil
+	for provider, keys := range apiKeys {
+		pool, err := newKeyPool(provider, keys, poolConfig)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create client for provider %s: %w", provider, err)
 		}
-		clients[provider] = client
+		clients[provider] = pool
+		pools[provider] = pool
 	}
 
-	return &UnifiedClient{
+	client := &UnifiedClient{
 		clients:              clients,
+		pools:                pools,
+		limiter:              newRateLimiter(),
 		customModelProviders: make(map[Model]Provider),
-	}, nil
+		fallbacks:            make(map[Model][]Model),
+		maxToken:             config.MaxToken,
+		tracing:              config.Tracing,
+	}
+	if len(config.Middleware) > 0 {
+		client.Use(config.Middleware...)
+	}
+	return client, nil
+}
+
+// SetCostTracker は、成功したリクエストの料金を記録する CostTracker を設定します。
+// GenTextParams.Tags と EmbedParams.Tags に指定したタグごとにも集計されます。nil を指定すると記録を停止します。
+func (c *UnifiedClient) SetCostTracker(tracker *CostTracker) {
+	c.costs = tracker
+}
+
+// recordCost は、CostTracker が設定されている場合にレスポンスの料金を記録します。
+func (c *UnifiedClient) recordCost(res *GenTextResponse, tags []string) {
+	if c.costs != nil {
+		c.costs.Record(res, tags...)
+	}
+}
+
+// KeyStats は、指定したプロバイダーのAPIキーごとの使用状況を返します。
+func (c *UnifiedClient) KeyStats(provider Provider) []KeyStats {
+	pool, ok := c.pools[provider]
+	if !ok {
+		return nil
+	}
+	return pool.stats()
 }
 
 // RegisterCustomModel は、カスタムモデル名とプロバイダーのマッピングを登録します。
@@ -118,16 +414,214 @@ func (c *UnifiedClient) getProviderForModel(model Model) Provider {
 
 // GenText は、モデル名から適切なプロバイダーを選択してテキストを生成します。
 func (c *UnifiedClient) GenText(params GenTextParams) (string, error, int) {
-	provider := c.getProviderForModel(params.Model)
+	return c.GenTextContext(context.Background(), params)
+}
+
+// GenTextContext は、コンテキストを指定し、モデル名から適切なプロバイダーを選択してテキストを生成します。
+func (c *UnifiedClient) GenTextContext(ctx context.Context, params GenTextParams) (string, error, int) {
+	res, err := c.Generate(ctx, params)
+	if err != nil {
+		return "", err, 0
+	}
+
+	return res.Text, nil, res.Usage.TotalTokens
+}
+
+// Generate は、モデル名から適切なプロバイダーを選択してテキストを生成し、詳細なレスポンスを返します。
+// フォールバックが設定されている場合は、障害や一時的なエラーで失敗したときに次のモデルを試行します。
+func (c *UnifiedClient) Generate(ctx context.Context, params GenTextParams) (*GenTextResponse, error) {
+	if c.handler != nil {
+		return collectResponse(c.handler(ctx, GenTextRequest{Params: params}))
+	}
+	return c.generateChain(ctx, params)
+}
+
+// generateChain は、フォールバックチェーンのモデルを順に試行してテキストを生成します。
+func (c *UnifiedClient) generateChain(ctx context.Context, params GenTextParams) (res *GenTextResponse, err error) {
+	chain := c.modelChain(params)
+	ctx, span := c.startFallbackSpan(ctx, chain)
+	failures := []FallbackFailure{}
+	defer func() { endFallbackSpan(span, res, len(failures), err) }()
+	for i, model := range chain {
+		client, err := c.clientForModel(model)
+		if err == nil {
+			var res *GenTextResponse
+			res, err = c.generate(ctx, client, fallbackParams(params, model))
+			if err == nil {
+				res.FallbackFailures = failures
+				c.recordCost(res, params.Tags)
+				return res, nil
+			}
+		}
+
+		if i == len(chain)-1 || !shouldFallback(err) {
+			return nil, fallbackError(failures, model, err)
+		}
+		failures = append(failures, c.fallbackFailure(model, err))
+	}
+
+	return nil, ErrInvalidModel
+}
+
+// GenTextStream は、モデル名から適切なプロバイダーを選択し、生成されたテキストを逐次返します。
+// フォールバックは、最初のイベントを受け取る前に失敗した場合にのみ行います。
+func (c *UnifiedClient) GenTextStream(ctx context.Context, params GenTextParams) iter.Seq2[StreamEvent, error] {
+	if c.handler != nil {
+		return c.handler(ctx, GenTextRequest{Params: params, Stream: true})
+	}
+	return c.streamChain(ctx, params)
+}
+
+// streamChain は、フォールバックチェーンのモデルを順に試行し、生成されたテキストを逐次返します。
+func (c *UnifiedClient) streamChain(ctx context.Context, params GenTextParams) iter.Seq2[StreamEvent, error] {
+	return func(yield func(StreamEvent, error) bool) {
+		chain := c.modelChain(params)
+		ctx, span := c.startFallbackSpan(ctx, chain)
+		failures := []FallbackFailure{}
+		var res *GenTextResponse
+		var chainErr error
+		defer func() { endFallbackSpan(span, res, len(failures), chainErr) }()
+		for i, model := range chain {
+			started := false
+			client, err := c.clientForModel(model)
+			if err == nil {
+				for event, streamErr := range c.stream(ctx, client, fallbackParams(params, model)) {
+					if streamErr != nil {
+						err = streamErr
+						break
+					}
+					started = true
+					if event.Response != nil {
+						event.Response.FallbackFailures = failures
+						c.recordCost(event.Response, params.Tags)
+						res = event.Response
+					}
+					if !yield(event, nil) {
+						return
+					}
+				}
+				if err == nil {
+					return
+				}
+			}
+
+			if started || i == len(chain)-1 || !shouldFallback(err) {
+				chainErr = fallbackError(failures, model, err)
+				yield(StreamEvent{}, chainErr)
+				return
+			}
+			failures = append(failures, c.fallbackFailure(model, err))
+		}
+	}
+}
+
+// Embed は、モデル名から適切なプロバイダーを選択して埋め込みベクトルを生成します。
+func (c *UnifiedClient) Embed(ctx context.Context, params EmbedParams) (*EmbedResponse, error) {
+	client, err := c.clientForModel(params.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	embedder, ok := client.(Embedder)
+	if !ok {
+		return nil, fmt.Errorf("%w: provider %s", ErrEmbeddingNotSupported, c.getProviderForModel(params.Model))
+	}
+
+	estimated := estimateEmbedTokens(params)
+	call, err := c.limiter.start(ctx, c.getProviderForModel(params.Model), params.Model, estimated)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := embedder.Embed(call.context(ctx), params)
+	if err != nil {
+		call.done(-1)
+		return nil, err
+	}
+	// Gemini はトークン数を返さないため、その場合は見積もりをそのまま使用量とみなします
+	if res.Usage.TotalTokens > 0 {
+		call.done(res.Usage.TotalTokens)
+	} else {
+		call.done(estimated)
+	}
+	if c.costs != nil {
+		c.costs.RecordEmbedding(res, params.Tags...)
+	}
+	return res, nil
+}
+
+// CountTokens は、モデル名から適切なプロバイダーを選択して入力トークン数を計算します。
+// OpenAIはローカルのトークナイザで、AnthropicとGeminiはAPIで計算します。
+func (c *UnifiedClient) CountTokens(ctx context.Context, params GenTextParams) (int, error) {
+	client, err := c.clientForModel(params.Model)
+	if err != nil {
+		return 0, err
+	}
+
+	counter, ok := client.(TokenCounter)
+	if !ok {
+		return 0, fmt.Errorf("%w: provider %s", ErrTokenCountingNotSupported, c.getProviderForModel(params.Model))
+	}
+	return counter.CountTokens(ctx, params)
+}
+
+// CreateCache は、モデル名から適切なプロバイダーを選択してコンテキストキャッシュを作成します。
+func (c *UnifiedClient) CreateCache(ctx context.Context, params CachedContentParams) (*CachedContent, error) {
+	manager, err := c.cacheManager(c.getProviderForModel(params.Model))
+	if err != nil {
+		return nil, err
+	}
+
+	return manager.CreateCache(ctx, params)
+}
+
+// ListCaches は、指定したプロバイダーのコンテキストキャッシュの一覧を返します。
+func (c *UnifiedClient) ListCaches(ctx context.Context, provider Provider) ([]CachedContent, error) {
+	manager, err := c.cacheManager(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	return manager.ListCaches(ctx)
+}
+
+// DeleteCache は、指定したプロバイダーのコンテキストキャッシュを削除します。
+func (c *UnifiedClient) DeleteCache(ctx context.Context, provider Provider, name string) error {
+	manager, err := c.cacheManager(provider)
+	if err != nil {
+		return err
+	}
+
+	return manager.DeleteCache(ctx, name)
+}
+
+// cacheManager は、プロバイダーのクライアントをコンテキストキャッシュの管理に使用できる形で返します。
+func (c *UnifiedClient) cacheManager(provider Provider) (CacheManager, error) {
+	client, ok := c.clients[provider]
+	if !ok {
+		return nil, fmt.Errorf("%w: no client for provider %s", ErrUnsupportedProvider, provider)
+	}
+
+	manager, ok := client.(CacheManager)
+	if !ok {
+		return nil, fmt.Errorf("%w: provider %s", ErrCacheManagementNotSupported, provider)
+	}
+
+	return manager, nil
+}
+
+// clientForModel は、モデル名に対応するプロバイダーのクライアントを返します。
+func (c *UnifiedClient) clientForModel(model Model) (LLMWrapper, error) {
+	provider := c.getProviderForModel(model)
 
 	if provider == "" {
-		return "", fmt.Errorf("%w: could not determine provider for model %s", ErrUnsupportedProvider, params.Model), 0
+		return nil, fmt.Errorf("%w: could not determine provider for model %s", ErrUnsupportedProvider, model)
 	}
 
 	client, ok := c.clients[provider]
 	if !ok {
-		return "", fmt.Errorf("%w: no client for provider %s", ErrUnsupportedProvider, provider), 0
+		return nil, fmt.Errorf("%w: no client for provider %s", ErrUnsupportedProvider, provider)
 	}
 
-	return client.GenText(params)
+	return client, nil
 }